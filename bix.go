@@ -5,11 +5,18 @@ import (
 	"bufio"
 	"bytes"
 	"compress/gzip"
+	"encoding/binary"
+	"fmt"
+	"hash"
+	"hash/crc32"
+	"hash/fnv"
 	"io"
 	"log"
+	"math"
 	"os"
 	"strconv"
 	"strings"
+	"sync"
 	"time"
 	"unsafe"
 
@@ -25,55 +32,217 @@ import (
 // Bix provides read access to tabix files.
 type Bix struct {
 	Index
-	bgzf    *bgzf.Reader
-	path    string
-	workers int
+	bgzf      BlockReader
+	path      string
+	indexPath string
+	workers   int
 
 	VReader *vcfgo.Reader
 	// index for 'ref' and 'alt' columns if they were present.
 	refalt []int
+	// the column names from a header line of a file that is not VCF.
+	columns []string
+	// the header lines of a file that is not VCF, without line endings.
+	header []string
+	// 1-based column holding the record length. 0 if the end is read from
+	// the index's EndColumn.
+	lengthColumn int
+	// field separator; a tab unless set with SetDelimiter.
+	delim byte
+	// if set, separators within double quotes do not split fields.
+	quoted bool
+	// compute a CRC32 of the compressed bytes read by full-file scans.
+	checksum bool
+	// if non-zero, only records with this value in the 1-based strandColumn
+	// are returned.
+	strand       byte
+	strandColumn int
+	// return ErrUnknownContig for queries on contigs not in the index.
+	strict bool
+	// keep a copy of the original line with each record.
+	rawLines bool
+	// treat the End() of query regions as inclusive.
+	inclusiveEnd bool
+	// return records that only touch the query region.
+	bookended bool
+	// reuse the line, fields and record between calls to Next.
+	reuse bool
+	// 1-based column holding feature names for QueryName; 0 for the
+	// default.
+	featureColumn int
+	// alternative contig names used by queries. see SetContigAliases.
+	aliases map[string]string
+	// naming of the contigs of returned records.
+	contigStyle ContigStyle
+	// lines for which lineFilter returns true are skipped.
+	lineFilter LineFilter
+	// opens the data file; bgzf if nil.
+	blockReader BlockReaderFunc
+	// size of the read buffer used by iterators; 0 for the bufio default.
+	bufSize int
+	// maximum length of a line; 0 for no limit.
+	maxLine int
+	// maximum span of a record returned by a region query; 0 for no limit.
+	maxSpan int
+	// lengths of contigs that the ends of records are clamped to.
+	contigLengths map[string]int
+	// if non-nil, called for each record whose end is clamped.
+	clampWarn func(chrom string, start, end, length int)
+	// columns set with SetColumns, used instead of the index's if columnsSet.
+	columnsSet                bool
+	nameCol, beginCol, endCol int
+	zeroBased                 bool
+	// zeroBased set with SetZeroBased.
+	zeroBasedSet bool
+	// queries share file and bgzf, one at a time. see NewSerial.
+	serial bool
+	mu     sync.Mutex
+	// an iterator from FastQuery is using file and bgzf.
+	fastQuery bool
+	// the fixed columns of a VCF whose #CHROM line does not have them in
+	// the usual order, or nil.
+	vcfCols *vcfColumns
+	// the files that queries are routed to. see NewMulti.
+	multi []*Bix
+	// the file is a stream from NewStream, with its first line after the
+	// header, which is read by the first Query(nil), if any.
+	stream     *streamFile
+	streamLine string
+	// Close has been called.
+	closed bool
 
-	file *os.File
+	file dataFile
 	buf  *bufio.Reader
 }
 
 func (tbx *Bix) init() error {
+	if tbx.closed {
+		return errors.Wrap(ErrClosed, tbx.path)
+	}
 	if tbx.file != nil {
 		return nil
 	}
-	var err error
-	tbx.file, err = os.Open(tbx.path)
+	f, err := openData(tbx.path)
 	if err != nil {
 		return errors.Wrapf(err, "bix: error (re)opening %s", tbx.path)
 	}
-	tbx.bgzf, err = bgzf.NewReader(tbx.file, tbx.workers)
-	if err != nil {
-		return errors.Wrapf(err, "bix: error creating new bgzf reader for %v", tbx.path)
+	if tbx.bgzf, err = tbx.openBlocks(f); err != nil {
+		f.Close()
+		return err
 	}
+	tbx.file = f
 	return nil
 }
 
-// create a new bix that does as little as possible from the old bix
+// create a new bix that does as little as possible from the old bix. The
+// new bix gets its own VReader (sharing the header) because vcfgo.Reader.Parse
+// records errors in the Reader and so is not safe for concurrent use.
 func newShort(old *Bix) (*Bix, error) {
+	if old.closed {
+		return nil, errors.Wrap(ErrClosed, old.path)
+	}
+	if old.multi != nil {
+		return nil, errMulti
+	}
+	if old.stream != nil {
+		return nil, errors.Errorf("bix: %s is a stream and cannot be reopened", old.path)
+	}
 	tbx := &Bix{
-		Index:   old.Index,
-		path:    old.path,
-		workers: old.workers,
-		VReader: old.VReader,
-		refalt:  old.refalt,
+		Index:     old.Index,
+		path:      old.path,
+		indexPath: old.indexPath,
+		workers:   old.workers,
+		VReader:   old.VReader,
+		refalt:    old.refalt,
+		columns:   old.columns,
+		header:    old.header,
+		vcfCols:   old.vcfCols,
 	}
+	tbx.copySettings(old)
 	var err error
-	tbx.file, err = os.Open(tbx.path)
+	if old.VReader != nil {
+		tbx.VReader, err = vcfgo.NewWithHeader(strings.NewReader(""), old.VReader.Header, true)
+		if err != nil {
+			return nil, errors.Wrapf(err, "bix: error creating vcf reader for %s", tbx.path)
+		}
+	}
+	tbx.file, err = openData(tbx.path)
 	if err != nil {
 		return nil, errors.Wrapf(err, "bix: error (re)opening %s", tbx.path)
 	}
-	tbx.bgzf, err = bgzf.NewReader(tbx.file, old.workers)
+	tbx.bgzf, err = tbx.openBlocks(tbx.file)
 	if err != nil {
-		return nil, errors.Wrapf(err, "bix: error creating new bgzf reader for %v", tbx.path)
+		return nil, err
+	}
+	return tbx, nil
+}
+
+// copySettings copies the settings made with the Set methods from old.
+func (tbx *Bix) copySettings(old *Bix) {
+	tbx.lengthColumn = old.lengthColumn
+	tbx.delim = old.delim
+	tbx.quoted = old.quoted
+	tbx.checksum = old.checksum
+	tbx.strand = old.strand
+	tbx.strandColumn = old.strandColumn
+	tbx.strict = old.strict
+	tbx.rawLines = old.rawLines
+	tbx.inclusiveEnd = old.inclusiveEnd
+	tbx.bookended = old.bookended
+	tbx.reuse = old.reuse
+	tbx.lineFilter = old.lineFilter
+	tbx.aliases = old.aliases
+	tbx.contigStyle = old.contigStyle
+	tbx.featureColumn = old.featureColumn
+	tbx.blockReader = old.blockReader
+	tbx.bufSize = old.bufSize
+	tbx.maxLine = old.maxLine
+	tbx.maxSpan = old.maxSpan
+	tbx.contigLengths = old.contigLengths
+	tbx.clampWarn = old.clampWarn
+	tbx.columnsSet = old.columnsSet
+	tbx.nameCol = old.nameCol
+	tbx.beginCol = old.beginCol
+	tbx.endCol = old.endCol
+	tbx.zeroBased = old.zeroBased
+	tbx.zeroBasedSet = old.zeroBasedSet
+}
+
+// Clone returns a copy of tbx with its own file handle that shares the index
+// and header but not the VCF parser, so the copy can be used in another
+// goroutine without reading the index again. Settings such as SetStrict are
+// copied; a copy of a Bix from NewSerial is not itself serial.
+func (tbx *Bix) Clone() (*Bix, error) {
+	return newShort(tbx)
+}
+
+// acquire returns the Bix to be used by a single query. This is a new Bix
+// with its own file handle unless tbx is serial, in which case it is tbx
+// itself, locked until release is called.
+func (tbx *Bix) acquire() (*Bix, error) {
+	if !tbx.serial {
+		return newShort(tbx)
+	}
+	tbx.mu.Lock()
+	if err := tbx.init(); err != nil {
+		tbx.mu.Unlock()
+		return nil, err
 	}
 	return tbx, nil
 }
 
+// release is called when a query from acquire is done.
+func (tbx *Bix) release() error {
+	if tbx.serial {
+		tbx.mu.Unlock()
+		return nil
+	}
+	// tbx is a copy from newShort or is used by FastQuery, which reopens the
+	// file for the next query.
+	tbx.closeFile()
+	return nil
+}
+
 func exists(path string) bool {
 	_, err := os.Stat(path)
 	return err == nil
@@ -90,320 +259,1979 @@ func getModTime(path string) time.Time {
 
 // New returns a &Bix
 func New(path string, workers ...int) (*Bix, error) {
-	var idx Index
 	var ext string
 
 	if exists(path + ".csi") {
 		ext = ".csi"
-	} else {
+	} else if exists(path + ".tbi") {
 		ext = ".tbi"
+	} else {
+		return nil, errors.Errorf("bix: no .tbi or .csi index found for %s (tried %s.tbi and %s.csi)", path, path, path)
 	}
 	if getModTime(path).After(getModTime(path + ext)) {
 		log.Printf("warning: data file %s is modified more recently than its index.", path)
 	}
 
-	f, err := os.Open(path + ext)
+	// the type of index is determined from its contents so that a misnamed
+	// index is still read correctly.
+	idx, err := readIndexFile(path + ext)
 	if err != nil {
-		return nil, errors.Wrapf(err, "bix: error on opening %s%s", path, ext)
+		return nil, err
 	}
-	defer f.Close()
+	n := 1
+	if len(workers) > 0 {
+		n = workers[0]
+	}
+	return newWithIndex(path, path+ext, idx, n, nil)
+}
 
-	gz, err := gzip.NewReader(f)
+// NewSerial returns a &Bix whose queries all share a single file handle
+// rather than opening the file for each query. Only one iterator may be open
+// at a time: Query and FastQuery block until the previous iterator has been
+// closed, so an iterator must be closed before the next query is made from
+// the same goroutine. This trades concurrency for fewer system calls when
+// making many small queries.
+func NewSerial(path string, workers ...int) (*Bix, error) {
+	tbx, err := New(path, workers...)
 	if err != nil {
-		return nil, errors.Wrapf(err, "bix: error on reading tabix index: %s%s", path, ext)
+		return nil, err
 	}
-	defer gz.Close()
+	tbx.serial = true
+	return tbx, nil
+}
 
-	if ext == ".tbi" {
-		t, err := tabix.ReadFrom(gz)
-		if err != nil {
-			return nil, errors.Wrapf(err, "bix: error parsing tabix index from: %s.tbi", path)
-		}
-		idx = tIndex{t}
-	} else {
-		idx, err = NewCSI(gz)
-		if err != nil {
-			return nil, errors.Wrapf(err, "bix: error parsing tabix index from: %s.csi", path)
-		}
+// NewWithIndex returns a &Bix for the data in dataPath using the index in
+// indexPath, which need not be next to the data file. The index may be
+// tabix or CSI.
+func NewWithIndex(dataPath, indexPath string, workers int) (*Bix, error) {
+	if getModTime(dataPath).After(getModTime(indexPath)) {
+		log.Printf("warning: data file %s is modified more recently than its index.", dataPath)
 	}
-	n := 1
-	if len(workers) > 0 {
-		n = workers[0]
+	idx, err := readIndexFile(indexPath)
+	if err != nil {
+		return nil, err
 	}
+	return newWithIndex(dataPath, indexPath, idx, workers, nil)
+}
 
-	b, err := os.Open(path)
+// NewWithBlockReader is like NewWithIndex but the data file is read with the
+// BlockReader returned by open rather than as bgzf. This allows querying
+// files that use another block compression but are indexed by virtual
+// offsets in the same way. BlockOffsets is only supported for bgzf.
+func NewWithBlockReader(dataPath, indexPath string, open BlockReaderFunc, workers int) (*Bix, error) {
+	idx, err := readIndexFile(indexPath)
 	if err != nil {
 		return nil, err
 	}
-	bgz, err := bgzf.NewReader(b, n)
+	return newWithIndex(dataPath, indexPath, idx, workers, open)
+}
+
+// NewFromIndex returns a &Bix for the data in dataPath using idx, an index
+// that the caller has read, for example with ReadIndex from storage other
+// than a file next to the data. Fingerprint is not supported as the index
+// has no file.
+func NewFromIndex(idx Index, dataPath string, workers int) (*Bix, error) {
+	if idx == nil {
+		return nil, errors.Errorf("bix: nil index for %s", dataPath)
+	}
+	return newWithIndex(dataPath, "", idx, workers, nil)
+}
+
+// ReadIndex reads a tabix or CSI index from r for use with NewFromIndex. The
+// index may be compressed, as it is on disk, or already decompressed.
+func ReadIndex(r io.Reader) (Index, error) {
+	return readIndexFrom(r, "reader")
+}
+
+// readIndexFile reads the tabix or CSI index at path. The index is usually
+// bgzf (and so gzip) compressed, but uncompressed indexes are also read.
+func readIndexFile(path string) (Index, error) {
+	f, err := os.Open(path)
 	if err != nil {
-		return nil, errors.Wrapf(err, "bix: error opening bgzf reader for %s", path)
+		return nil, errors.Wrapf(err, "bix: error on opening %s", path)
 	}
+	defer f.Close()
+	return readIndexFrom(f, path)
+}
 
-	var h []string
-	tbx := &Bix{bgzf: bgz, path: path, file: b, workers: n}
+// readIndexFrom reads the tabix or CSI index at path from r.
+func readIndexFrom(r io.Reader, path string) (Index, error) {
+	r = bufio.NewReader(r)
+	if magic, err := r.(*bufio.Reader).Peek(2); err == nil && magic[0] == 0x1f && magic[1] == 0x8b {
+		gz, err := gzip.NewReader(r)
+		if err != nil {
+			return nil, errors.Wrapf(err, "bix: error on reading index: %s", path)
+		}
+		defer gz.Close()
+		r = gz
+	}
 
-	buf := bufio.NewReader(bgz)
-	l, err := buf.ReadString('\n')
+	idx, err := readIndex(r)
 	if err != nil {
-		return tbx, errors.Wrapf(err, "bix: error reading line from %s", path)
+		return nil, errors.Wrapf(err, "bix: error parsing index from: %s", path)
 	}
+	return idx, nil
+}
 
-	for i := 0; i < int(idx.Skip()) || rune(l[0]) == idx.MetaChar(); i++ {
-		h = append(h, l)
-		l, err = buf.ReadString('\n')
+// readIndex reads a tabix or CSI index from r, using the magic number to
+// determine the format.
+func readIndex(r io.Reader) (Index, error) {
+	br := bufio.NewReader(r)
+	magic, err := br.Peek(4)
+	if err != nil {
+		return nil, err
+	}
+	switch {
+	case bytes.Equal(magic, []byte("TBI\x01")):
+		t, err := tabix.ReadFrom(br)
+		if err != nil {
+			return nil, err
+		}
+		if t == nil {
+			// biogo returns no index for a file without records, so the
+			// header, which follows the number of references, is read here.
+			var h struct{ Format, Name, Begin, End, Meta, Skip int32 }
+			if err := binary.Read(br, binary.LittleEndian, &h); err != nil {
+				return nil, err
+			}
+			t = tabix.New()
+			t.Format, t.ZeroBased = byte(h.Format), h.Format&0x10000 != 0
+			t.NameColumn, t.BeginColumn, t.EndColumn = h.Name, h.Begin, h.End
+			t.MetaChar, t.Skip = rune(h.Meta), h.Skip
+		}
+		return tIndex{t}, nil
+	case bytes.Equal(magic[:3], []byte("CSI")):
+		return NewCSI(br)
+	}
+	return nil, errors.Errorf("bix: unknown index magic %q; expected tabix (TBI\\1) or CSI", magic)
+}
+
+// newWithIndex opens the data file at path with open, or as bgzf if open is
+// nil, and reads its header. idx was read from indexPath.
+func newWithIndex(path, indexPath string, idx Index, n int, open BlockReaderFunc) (*Bix, error) {
+	// an end column before the begin column is not used by any tabix preset
+	// and gives nonsense intervals, so it is taken to be a broken index.
+	if idx.EndColumn() != 0 && idx.EndColumn() < idx.BeginColumn() {
+		return nil, errors.Errorf("bix: index %s has end column %d before begin column %d", indexPath, idx.EndColumn(), idx.BeginColumn())
+	}
+	b, err := openData(path)
+	if err != nil {
+		return nil, err
+	}
+	if open == nil {
+		// check for plain gzip, which gives cryptic errors from bgzf.
+		gz, bgz, err := compression(b)
 		if err != nil {
-			return tbx, errors.Wrapf(err, "bix: error reading line from %s", path)
+			b.Close()
+			return nil, errors.Wrapf(err, "bix: error reading %s", path)
 		}
+		if gz && !bgz {
+			b.Close()
+			return nil, errors.Errorf("bix: %s is gzip but not bgzf; indexed queries unavailable (recompress it with bgzip)", path)
+		}
+	}
+	tbx := &Bix{path: path, indexPath: indexPath, file: b, workers: n, delim: '\t', strandColumn: 6, blockReader: open}
+	bgz, err := tbx.openBlocks(b)
+	if err != nil {
+		b.Close()
+		return nil, err
+	}
+	tbx.bgzf = bgz
+	if _, err := tbx.readFileHeader(idx); err != nil {
+		return tbx, err
+	}
+	return tbx, nil
+}
+
+// readFileHeader reads the header from tbx.bgzf, which must be at the start
+// of the file, and sets up tbx for VCF or for the columns named in the
+// header. It returns the first line after the header.
+func (tbx *Bix) readFileHeader(idx Index) (string, error) {
+	buf := bufio.NewReader(tbx.bgzf)
+	h, first, err := readHeader(buf, idx.Skip(), idx.MetaChar())
+	if err != nil {
+		return "", errors.Wrapf(err, "bix: error reading line from %s", tbx.path)
 	}
 	header := strings.Join(h, "")
 
-	if len(h) > 0 && (strings.HasSuffix(tbx.path, ".vcf.gz") || strings.HasSuffix(tbx.path, ".vcf.bgz")) {
+	isVCF := strings.HasSuffix(tbx.path, ".vcf.gz") || strings.HasSuffix(tbx.path, ".vcf.bgz") ||
+		idx.Format()&0xffff == FormatVCF || isVCFHeader(h)
+	if len(h) > 0 && isVCF {
 		var err error
 		h := strings.NewReader(header)
 
 		tbx.VReader, err = vcfgo.NewReader(h, true)
 		if err != nil {
-			return nil, err
-		}
-	} else if len(h) > 0 {
-		htab := strings.Split(strings.TrimSpace(h[len(h)-1]), "\t")
-		// try to find ref and alternate columns to make an IREFALT
-		for i, hdr := range htab {
-			if l := strings.ToLower(hdr); l == "ref" || l == "reference" {
-				tbx.refalt = append(tbx.refalt, i)
-				break
-			}
+			return "", err
 		}
-		for i, hdr := range htab {
-			if l := strings.ToLower(hdr); l == "alt" || l == "alternate" {
-				tbx.refalt = append(tbx.refalt, i)
-				break
-			}
+		if tbx.vcfCols, err = parseVCFColumns(header); err != nil {
+			return "", errors.Wrapf(err, "bix: error reading header of %s", tbx.path)
 		}
-		if len(tbx.refalt) != 2 {
-			tbx.refalt = nil
+	} else if len(h) > 0 {
+		tbx.header = make([]string, len(h))
+		for i, l := range h {
+			tbx.header[i] = strings.TrimRight(l, "\r\n")
 		}
+		tbx.useHeaderLine(tbx.header[columnLine(tbx.header, first)], idx.MetaChar())
 	}
 	tbx.buf = buf
 	tbx.Index = idx
-	return tbx, nil
+	return first, nil
 }
 
-func (b *Bix) Close() error {
-	b.bgzf.Close()
-	b.file.Close()
-	return nil
+// MetaChar returns the character that starts header and comment lines.
+func (tbx *Bix) MetaChar() rune {
+	return tbx.Index.MetaChar()
 }
 
-func (tbx *Bix) toPosition(toks [][]byte) interfaces.Relatable {
-	isVCF := tbx.VReader != nil
-	var g *parsers.Interval
+// SkipLines returns the number of lines at the start of the file that are
+// skipped as header regardless of their first character.
+func (tbx *Bix) SkipLines() int {
+	return tbx.Index.Skip()
+}
 
-	if isVCF {
-		v := tbx.VReader.Parse(toks)
-		return interfaces.AsRelatable(v)
+// SetChecksum enables computing a CRC32 (IEEE) of the raw, compressed bytes
+// read during full-file scans (Query with a nil region). The iterator from
+// such a scan is a Checksummer and, once it has returned io.EOF, its checksum
+// covers the entire file so it can be compared between runs.
+func (tbx *Bix) SetChecksum(enabled bool) {
+	tbx.checksum = enabled
+}
 
-	} else {
-		g, _ = newgeneric(toks, tbx.Index.NameColumn()-1, tbx.Index.BeginColumn()-1,
-			tbx.Index.EndColumn()-1, tbx.Index.ZeroBased())
-	}
-	if tbx.refalt != nil {
-		ra := parsers.RefAltInterval{Interval: *g, HasEnd: tbx.Index.EndColumn() != tbx.Index.BeginColumn()}
-		ra.SetRefAlt(tbx.refalt)
-		return &ra
+// Checksummer is implemented by the iterators from Query.
+type Checksummer interface {
+	// Checksum returns the CRC32 of the compressed bytes read so far. It is
+	// 0 unless checksums were enabled with SetChecksum.
+	Checksum() uint32
+}
+
+// SetDelimiter sets the byte that separates fields. The default is a tab.
+func (tbx *Bix) SetDelimiter(d byte) {
+	tbx.delim = d
+}
+
+// SetQuoted sets whether fields may be double-quoted, as in CSV, so that a
+// delimiter within quotes does not end the field. The quotes are kept in
+// the field. It is off by default as it is slower, and is not used for VCF.
+func (tbx *Bix) SetQuoted(quoted bool) {
+	tbx.quoted = quoted
+}
+
+// SetStrand limits the records returned to those with the given strand,
+// which must be '+' or '-'. A value of 0 returns records from both strands.
+func (tbx *Bix) SetStrand(s byte) error {
+	if s != 0 && s != '+' && s != '-' {
+		return errors.Errorf("bix: invalid strand %q", s)
 	}
-	return g
+	tbx.strand = s
+	return nil
 }
 
-func unsafeString(b []byte) string {
-	return *(*string)(unsafe.Pointer(&b))
+// SetStrandColumn sets the 1-based column used by SetStrand. The default is
+// 6, as in BED.
+func (tbx *Bix) SetStrandColumn(col int) {
+	tbx.strandColumn = col
 }
 
-// return an interval using the info from the tabix index
-func newgeneric(fields [][]byte, chromCol int, startCol int, endCol int, zeroBased bool) (*parsers.Interval, error) {
-	s, err := strconv.Atoi(unsafeString(fields[startCol]))
-	if err != nil {
-		return nil, err
+// LineFilter reports whether a line, without its line terminator, should be
+// skipped.
+type LineFilter func(line []byte) bool
+
+// NumWorkers returns the number of goroutines used to decompress the file
+// for each query.
+func (tbx *Bix) NumWorkers() int {
+	return tbx.workers
+}
+
+// SetWorkers sets the number of goroutines used to decompress the file for
+// queries made after the call. It returns an error if an iterator from
+// FastQuery, or any iterator from a Bix from NewSerial, is open, as those
+// share the reader of tbx. Iterators from Query have their own readers and
+// are not affected. SetWorkers must not be called concurrently with Query
+// unless tbx is from NewSerial. It returns an error for a Bix from NewStream,
+// whose reader cannot be reopened.
+func (tbx *Bix) SetWorkers(n int) error {
+	if n < 1 {
+		return errors.Errorf("bix: invalid number of workers %d", n)
 	}
-	if !zeroBased {
-		s -= 1
+	if tbx.stream != nil {
+		return errors.Errorf("bix: %s is a stream and cannot be reopened with %d workers", tbx.path, n)
 	}
-	e, err := strconv.Atoi(unsafeString(fields[endCol]))
-	if err != nil {
-		return nil, err
+	if tbx.serial {
+		if !tbx.mu.TryLock() {
+			return errors.Errorf("bix: cannot set workers for %s while a query is open", tbx.path)
+		}
+		defer tbx.mu.Unlock()
+	} else if tbx.fastQuery {
+		return errors.Errorf("bix: cannot set workers for %s while a query is open", tbx.path)
 	}
-	return parsers.NewInterval(string(fields[chromCol]), uint32(s), uint32(e), fields, uint32(0), nil), nil
+	tbx.workers = n
+	for _, b := range tbx.multi {
+		b.workers = n
+		b.closeFile()
+	}
+	// the reader is reopened with n workers when it is next needed.
+	tbx.closeFile()
+	return nil
 }
 
-func (tbx *Bix) ChunkedReader(chrom string, start, end int) (io.ReadCloser, error) {
-	chunks, err := tbx.Chunks(chrom, start, end)
-	if err == index.ErrNoReference {
-		if strings.HasPrefix(chrom, "chr") {
-			chunks, err = tbx.Chunks(chrom[3:], start, end)
-		} else {
-			chunks, err = tbx.Chunks("chr"+chrom, start, end)
-		}
+// SetLineFilter sets a filter for lines that are not records but do not
+// start with the index's meta char, such as the "track" and "browser" lines
+// of BED files. Lines for which skip returns true are ignored by all
+// queries, including full scans. A nil skip, the default, skips only blank
+// lines.
+func (tbx *Bix) SetLineFilter(skip LineFilter) {
+	tbx.lineFilter = skip
+}
+
+// SetReuseBuffers enables a low-allocation path for files that are not VCF.
+// The line, its fields and the record returned by an iterator's Next, a
+// *parsers.Interval or, for files with ref and alt columns, a
+// *parsers.RefAltInterval, are reused, and the record's Chrom() refers to
+// the line rather than a copy, so a record is only valid until the following
+// call to Next or Peek. Callers that keep records must copy them.
+func (tbx *Bix) SetReuseBuffers(reuse bool) {
+	tbx.reuse = reuse
+}
+
+// SetBufferSize sets the size of the buffer used to read lines for queries.
+// The default is 4KB. A buffer larger than the typical line is faster for
+// files with very long lines, such as VCFs with many samples. A size of 0
+// restores the default.
+func (tbx *Bix) SetBufferSize(n int) {
+	tbx.bufSize = n
+}
+
+// newReader returns a buffered reader of the size set with SetBufferSize.
+func (tbx *Bix) newReader(r io.Reader) *bufio.Reader {
+	if tbx.bufSize > 0 {
+		return bufio.NewReaderSize(r, tbx.bufSize)
 	}
-	if err == index.ErrInvalid {
-		return index.NewChunkReader(tbx.bgzf, []bgzf.Chunk{})
-	} else if err == index.ErrNoReference {
-		log.Printf("chromosome %s not found in %s\n", chrom, tbx.path)
-		return index.NewChunkReader(tbx.bgzf, []bgzf.Chunk{})
-	} else if err != nil {
-		return nil, errors.Wrapf(err, "bix: error reading Chunks from %s", tbx.path)
+	return bufio.NewReader(r)
+}
+
+// ErrLineTooLong is the cause of the error returned when a line is longer
+// than the limit set with SetMaxLineLength.
+var ErrLineTooLong = errors.New("bix: line too long")
+
+// SetMaxLineLength sets the maximum length in bytes of a line, including its
+// newline, that queries and Validate will read. A longer line, such as the
+// whole of a corrupt file that has no newlines, gives an error with
+// ErrLineTooLong as its cause and the offset of the bgzf block being read,
+// rather than being read into memory. A limit of 0, the default, means no
+// limit.
+func (tbx *Bix) SetMaxLineLength(n int) {
+	tbx.maxLine = n
+}
+
+// SetContigLengths sets the length of each contig, as from the .fai of the
+// reference, so that the end of a record past the end of its contig, as
+// can happen with the INFO END of a structural variant, is clamped to the
+// length. A record that starts past the end of its contig gets an end equal
+// to its start. A contig may be named with or without a "chr" prefix.
+// Contigs without a length are not clamped. A nil map, the default, turns
+// this off. Records are clamped silently unless SetClampWarning is used.
+func (tbx *Bix) SetContigLengths(lengths map[string]int) {
+	if lengths == nil {
+		tbx.contigLengths = nil
+		return
 	}
-	cr, err := index.NewChunkReader(tbx.bgzf, chunks)
-	if err != nil {
-		return nil, errors.Wrapf(err, "bix: error creating chunked reader from %s", tbx.path)
+	tbx.contigLengths = make(map[string]int, len(lengths))
+	for k, v := range lengths {
+		tbx.contigLengths[k] = v
 	}
-	return cr, nil
 }
 
-// bixerator meets interfaces.RelatableIterator
-type bixerator struct {
-	rdr io.ReadCloser
-	buf *bufio.Reader
-	tbx *Bix
-
-	region interfaces.IPosition
+// SetClampWarning sets a function that is called with the 0-based start and
+// end of each record whose end is clamped by SetContigLengths, and the length
+// of its contig, for example to log the records. A nil warn, the default,
+// turns this off.
+func (tbx *Bix) SetClampWarning(warn func(chrom string, start, end, length int)) {
+	tbx.clampWarn = warn
 }
 
-func makeFields(line []byte) [][]byte {
-	fields := make([][]byte, 9)
-	copy(fields[:8], bytes.SplitN(line, []byte{'\t'}, 8))
-	s := 0
-	for i, f := range fields {
-		if i == 7 {
-			break
-		}
-		s += len(f) + 1
+// clampEnd returns end, or the length of chrom if end is past it, but not
+// less than start.
+func (tbx *Bix) clampEnd(chrom string, start, end uint32) uint32 {
+	if tbx.contigLengths == nil {
+		return end
 	}
-	/*
-		if s >= len(line) {
-			c := make([]string, len(fields))
-			for k, f := range fields {
-				c[k] = string(f)
-			}
-			log.Println(s, string(line), c)
+	l, ok := tbx.contigLengths[chrom]
+	if !ok {
+		if c, found := strings.CutPrefix(chrom, "chr"); found {
+			l, ok = tbx.contigLengths[c]
+		} else {
+			l, ok = tbx.contigLengths["chr"+chrom]
 		}
-	*/
-	e := bytes.IndexByte(line[s:], '\t')
-	if e == -1 {
-		e = len(line)
-	} else {
-		e += s
 	}
-
-	fields[7] = line[s:e]
-	if len(line) > e+1 {
-		fields[8] = line[e+1:]
-	} else {
-		fields = fields[:8]
+	if !ok || l < 0 || end <= uint32(l) {
+		return end
+	}
+	if tbx.clampWarn != nil {
+		tbx.clampWarn(chrom, int(start), int(end), l)
+	}
+	if start > uint32(l) {
+		return start
 	}
+	return uint32(l)
+}
 
-	return fields
+// SetMaxSpan sets the greatest span in bases, end minus start, of the
+// records returned by queries of a region, so that huge features such as
+// large structural variants are left out of a query for small, local ones.
+// The span of a VCF record is that of its ref or, for a symbolic allele, to
+// its INFO END. It does not apply to Query(nil). A span of 0, the default,
+// means no limit.
+func (tbx *Bix) SetMaxSpan(bp int) {
+	tbx.maxSpan = bp
 }
 
-func (b bixerator) Next() (interfaces.Relatable, error) {
+// inSpan returns true if a record from start to end is within the span set
+// with SetMaxSpan.
+func (tbx *Bix) inSpan(start, end int) bool {
+	return tbx.maxSpan == 0 || end-start <= tbx.maxSpan
+}
 
+// readLine appends the next line from buf, including its newline, to line.
+// If max is not 0, it stops with ErrLineTooLong once the line is longer.
+func readLine(buf *bufio.Reader, line []byte, max int) ([]byte, error) {
 	for {
-		line, err := b.buf.ReadBytes('\n')
-
-		if err == io.EOF && len(line) == 0 {
-			return nil, io.EOF
-		} else if err != nil {
-			return nil, errors.Wrapf(err, "bix: error iterating on %s", b.tbx.path)
-		}
-		if len(line) == 0 {
-			return nil, io.EOF
+		l, err := buf.ReadSlice('\n')
+		line = append(line, l...)
+		if max > 0 && len(line) > max {
+			return line, ErrLineTooLong
 		}
-		if line[len(line)-1] == '\n' {
-			line = line[:len(line)-1]
+		if err != bufio.ErrBufferFull {
+			return line, err
 		}
-		in := true
-		var toks [][]byte
-		if b.region != nil {
-			var err error
+	}
+}
 
-			in, err, toks = b.inBounds(line)
-			if err != nil {
-				return nil, err
-			}
-		} else {
-			if b.tbx.VReader != nil {
-				toks = makeFields(line)
-			} else {
-				toks = bytes.Split(line, []byte{'\t'})
+// SetColumns sets the 1-based columns holding the chromosome, start and end
+// of each record and whether the start is 0-based, overriding the values in
+// the index. This is for files whose index does not describe their columns
+// correctly. An end of 0 means records cover a single base. It does not
+// change how the index is searched.
+func (tbx *Bix) SetColumns(name, begin, end int, zeroBased bool) {
+	tbx.columnsSet = true
+	tbx.nameCol, tbx.beginCol, tbx.endCol = name, begin, end
+	tbx.zeroBased = zeroBased
+}
+
+// columnLine returns the index of the header line that holds the column
+// names: the last with as many fields as first, the first data line, or the
+// last line if there is none, so that comments after the column names are
+// not taken to be them.
+func columnLine(header []string, first string) int {
+	if first = strings.TrimRight(first, "\r\n"); first != "" {
+		n := strings.Count(first, "\t")
+		for i := len(header) - 1; i >= 0; i-- {
+			if strings.Count(header[i], "\t") == n {
+				return i
 			}
 		}
+	}
+	return len(header) - 1
+}
 
-		if in {
-			return b.tbx.toPosition(toks), nil
+// useHeaderLine takes the column names from the header line l and finds the
+// ref and alt columns if it names them.
+func (tbx *Bix) useHeaderLine(l string, meta rune) {
+	htab := strings.Split(strings.TrimSpace(l), "\t")
+	tbx.columns = append([]string{strings.TrimLeft(htab[0], string(meta))}, htab[1:]...)
+	tbx.refalt = nil
+	// try to find ref and alternate columns to make an IREFALT
+	for i, hdr := range htab {
+		if l := strings.ToLower(hdr); l == "ref" || l == "reference" {
+			tbx.refalt = append(tbx.refalt, i)
+			break
+		}
+	}
+	for i, hdr := range htab {
+		if l := strings.ToLower(hdr); l == "alt" || l == "alternate" {
+			tbx.refalt = append(tbx.refalt, i)
+			break
 		}
 	}
-	return nil, io.EOF
+	if len(tbx.refalt) != 2 {
+		tbx.refalt = nil
+	}
 }
 
-func (b bixerator) Close() error {
-	if b.rdr != nil {
-		b.rdr.Close()
+// SetHeaderLine sets the 1-based line of the header that holds the column
+// names given by ColumnNames and used to find the ref and alt columns. Blank
+// lines are not counted unless they are skipped with the index's Skip. By
+// default, it is the last header line with as many fields as the first
+// record, so comment lines after the column names are skipped. It returns an
+// error for VCF or if there is no such line.
+func (tbx *Bix) SetHeaderLine(n int) error {
+	if tbx.VReader != nil {
+		return errors.Errorf("bix: %s is VCF, whose column names are fixed", tbx.path)
+	}
+	if n < 1 || n > len(tbx.header) {
+		return errors.Errorf("bix: no header line %d in %s, which has %d", n, tbx.path, len(tbx.header))
 	}
-	return b.tbx.Close()
+	tbx.useHeaderLine(tbx.header[n-1], tbx.MetaChar())
+	return nil
 }
 
-var _ interfaces.RelatableIterator = bixerator{}
+// SetRefAlt sets the 1-based columns holding the reference and alternate
+// alleles of records in files that are not VCF, so that records satisfy
+// interfaces.IRefAlt. These are otherwise found from the header if it names
+// them. A ref of 0 means records have no ref and alt.
+func (tbx *Bix) SetRefAlt(ref, alt int) {
+	if ref == 0 {
+		tbx.refalt = nil
+		return
+	}
+	tbx.refalt = []int{ref - 1, alt - 1}
+}
 
-// FastQuery allows extracting intervals from an indexed file. Use this function if
-// concurrency is *not* required, otherwise use Query
-func (tbx *Bix) FastQuery(region interfaces.IPosition) (interfaces.RelatableIterator, error) {
-	if err := tbx.init(); err != nil {
-		return nil, err
+// NameColumn returns the 1-based column holding the chromosome.
+func (tbx *Bix) NameColumn() int {
+	if tbx.columnsSet {
+		return tbx.nameCol
 	}
-	cr, err := tbx.ChunkedReader(region.Chrom(), int(region.Start()), int(region.End()))
-	if err != nil {
-		if cr != nil {
-			cr.Close()
-			tbx.Close()
-		}
-		return nil, err
+	return tbx.Index.NameColumn()
+}
+
+// BeginColumn returns the 1-based column holding the start.
+func (tbx *Bix) BeginColumn() int {
+	if tbx.columnsSet {
+		return tbx.beginCol
 	}
-	return bixerator{cr, bufio.NewReader(cr), tbx, region}, nil
+	return tbx.Index.BeginColumn()
 }
 
-// Query allows extracting intervals from an indexed file. Use this function if
-// concurrency is required, otherwise use FastQuery
-func (tbx *Bix) Query(region interfaces.IPosition) (interfaces.RelatableIterator, error) {
-	tbx2, err := newShort(tbx)
-	if err != nil {
-		return nil, err
+// EndColumn returns the 1-based column holding the end or 0 if there is none.
+func (tbx *Bix) EndColumn() int {
+	if tbx.columnsSet {
+		return tbx.endCol
 	}
-	if region == nil {
-		var l string
-		var err error
-		buf := bufio.NewReader(tbx2.bgzf)
-		l, err = buf.ReadString('\n')
-		for i := 0; i < tbx2.Index.Skip() || rune(l[0]) == tbx2.Index.MetaChar(); i++ {
-			l, err = buf.ReadString('\n')
-			if err != nil {
-				return nil, err
+	return tbx.Index.EndColumn()
+}
+
+// SetZeroBased sets whether the start column of records is 0-based,
+// overriding the flag in the index, which is wrong for some files. For
+// example, a BED file indexed without the -0 option of tabix has a 1-based
+// flag but 0-based starts. It is ignored for VCF, which is always 1-based,
+// and does not change how the index is searched.
+func (tbx *Bix) SetZeroBased(zeroBased bool) {
+	tbx.zeroBasedSet = true
+	tbx.zeroBased = zeroBased
+}
+
+// ZeroBased returns true if the start is 0-based.
+func (tbx *Bix) ZeroBased() bool {
+	if tbx.columnsSet || (tbx.zeroBasedSet && tbx.VReader == nil) {
+		return tbx.zeroBased
+	}
+	return tbx.Index.ZeroBased()
+}
+
+// SetLengthColumn indicates that the end of each record is its start plus
+// the value in the given (1-based) column. This is for formats that store a
+// length rather than an end and that are indexed with EndColumn equal to
+// BeginColumn. A value of 0 reverts to using the EndColumn from the index.
+func (tbx *Bix) SetLengthColumn(col int) {
+	tbx.lengthColumn = col
+}
+
+// readHeader reads the header from the start of a file: exactly skip lines
+// and then any lines that start with meta. Blank lines after the first skip
+// lines are discarded. It returns the header lines and the first line after
+// them, which is empty if there are no more lines.
+func readHeader(buf *bufio.Reader, skip int, meta rune) ([]string, string, error) {
+	var h []string
+	for {
+		l, err := buf.ReadString('\n')
+		if err != nil && err != io.EOF {
+			return nil, "", err
+		}
+		if l == "" {
+			return h, "", nil
+		}
+		if len(h) >= skip && strings.TrimRight(l, "\r\n") == "" {
+			if err == io.EOF {
+				return h, "", nil
+			}
+			continue
+		}
+		if len(h) >= skip && rune(l[0]) != meta {
+			return h, l, nil
+		}
+		h = append(h, l)
+		if err == io.EOF {
+			return h, "", nil
+		}
+	}
+}
+
+// vcfColumns holds the 0-based index in a line of each of the fixed columns
+// of a VCF, in the order CHROM, POS, ID, REF, ALT, QUAL, FILTER and INFO.
+type vcfColumns [8]int
+
+// the indexes in vcfColumns of the columns used to find the bounds of a record.
+const (
+	vcfRef  = 3
+	vcfAlt  = 4
+	vcfInfo = 7
+)
+
+var vcfColumnNames = [8]string{"CHROM", "POS", "ID", "REF", "ALT", "QUAL", "FILTER", "INFO"}
+
+// parseVCFColumns returns the layout of the fixed columns in the #CHROM line
+// of header, or nil if they are in the usual order or there is no #CHROM
+// line. The VCF specification fixes the order, but as the columns are named
+// in the header, they are found by name, allowing any order within the
+// first 8 columns. The sample columns must follow them.
+func parseVCFColumns(header string) (*vcfColumns, error) {
+	var line string
+	for _, l := range strings.Split(header, "\n") {
+		if strings.HasPrefix(l, "#CHROM") {
+			line = l
+		}
+	}
+	if line == "" {
+		return nil, nil
+	}
+	names := strings.Split(strings.TrimRight(strings.TrimPrefix(line, "#"), "\r"), "\t")
+	var cols vcfColumns
+	standard := true
+	for i, want := range vcfColumnNames {
+		cols[i] = -1
+		for j := 0; j < len(names) && j < len(cols); j++ {
+			if names[j] == want {
+				cols[i] = j
+			}
+		}
+		if cols[i] == -1 {
+			return nil, errors.Errorf("bix: VCF column %s not found in the first 8 columns of %q", want, line)
+		}
+		standard = standard && cols[i] == i
+	}
+	if standard {
+		return nil, nil
+	}
+	return &cols, nil
+}
+
+// reorder returns the fields of a line with the fixed columns in the usual
+// order.
+func (c *vcfColumns) reorder(toks [][]byte) [][]byte {
+	out := make([][]byte, len(toks))
+	for i, j := range c {
+		out[i] = toks[j]
+	}
+	copy(out[len(c):], toks[len(c):])
+	return out
+}
+
+// isVCFHeader returns true if the header lines start with the VCF fileformat
+// line and include the #CHROM line.
+func isVCFHeader(h []string) bool {
+	if len(h) == 0 || !strings.HasPrefix(h[0], "##fileformat=VCF") {
+		return false
+	}
+	for _, l := range h {
+		if strings.HasPrefix(l, "#CHROM") {
+			return true
+		}
+	}
+	return false
+}
+
+// ErrClosed is the cause of the error returned by queries on a Bix after
+// Close has been called.
+var ErrClosed = errors.New("bix: use of closed Bix")
+
+// Close closes the file and releases the buffers held by tbx. The index and
+// header are kept, so VReader.Header may still be used, but queries made
+// after Close return an error with ErrClosed as its cause. Iterators from
+// Query that are still open have their own files and are not affected.
+func (b *Bix) Close() error {
+	for _, m := range b.multi {
+		m.Close()
+	}
+	b.closeFile()
+	b.closed = true
+	b.buf = nil
+	if b.VReader != nil {
+		// drop the parse errors collected by FastQuery.
+		b.VReader.Clear()
+	}
+	return nil
+}
+
+// closeFile closes the file, which init reopens when it is next needed.
+func (b *Bix) closeFile() {
+	b.fastQuery = false
+	if b.file == nil {
+		return
+	}
+	b.bgzf.Close()
+	b.file.Close()
+	b.bgzf, b.file = nil, nil
+}
+
+// EndVariant is a VCF record whose end differs from the End of its
+// *vcfgo.Variant. Query returns one, wrapped as other variants are, for a
+// record with a symbolic allele whose INFO END is not what vcfgo computes,
+// as for <INV> or <NON_REF> alleles or an SVLEN that disagrees with END,
+// and for a record clamped by SetContigLengths. Other VCF records are
+// returned as a *vcfgo.Variant.
+type EndVariant struct {
+	*vcfgo.Variant
+	end uint32
+}
+
+// End returns the end of the record from its INFO END or its contig length.
+func (v *EndVariant) End() uint32 { return v.end }
+
+// symbolicAlt returns true if any allele in the ALT field alt is symbolic,
+// such as <DEL> or <NON_REF>.
+func symbolicAlt(alt []byte) bool {
+	for _, a := range bytes.Split(alt, []byte{','}) {
+		if len(a) > 0 && a[0] == '<' {
+			return true
+		}
+	}
+	return false
+}
+
+// infoEnd returns the value of the END field of the INFO field info and
+// whether it is present.
+func infoEnd(info []byte) (int, bool, error) {
+	for _, f := range bytes.Split(info, []byte{';'}) {
+		if !bytes.HasPrefix(f, []byte("END=")) {
+			continue
+		}
+		e, err := strconv.Atoi(string(f[4:]))
+		if err != nil {
+			return 0, false, errors.Wrapf(err, "bix: invalid END in INFO %q", info)
+		}
+		return e, true, nil
+	}
+	return 0, false, nil
+}
+
+func (tbx *Bix) toPosition(toks [][]byte) interfaces.Relatable {
+	isVCF := tbx.VReader != nil
+	var g *parsers.Interval
+
+	if isVCF {
+		if tbx.vcfCols != nil {
+			toks = tbx.vcfCols.reorder(toks)
+		}
+		v := tbx.VReader.Parse(toks)
+		end, found := uint32(0), false
+		if symbolicAlt(toks[4]) {
+			if e, ok, err := infoEnd(toks[7]); err == nil && ok {
+				end, found = uint32(e), true
+			}
+		}
+		if !found && tbx.contigLengths == nil {
+			return interfaces.AsRelatable(v)
+		}
+		// vcfgo uses END for most symbolic alleles, so v is only wrapped
+		// if its End disagrees.
+		vend := v.End()
+		if !found {
+			end = vend
+		}
+		if end = tbx.clampEnd(v.Chrom(), v.Start(), end); end != vend {
+			return interfaces.AsRelatable(&EndVariant{Variant: v, end: end})
+		}
+		return interfaces.AsRelatable(v)
+
+	} else {
+		g, _ = newgeneric(toks, tbx.NameColumn()-1, tbx.BeginColumn()-1,
+			tbx.EndColumn()-1, tbx.lengthColumn-1, tbx.ZeroBased())
+		if e := tbx.clampEnd(g.Chrom(), g.Start(), g.End()); e != g.End() {
+			g = parsers.NewInterval(g.Chrom(), g.Start(), e, g.Fields, 0, nil)
+		}
+	}
+	if tbx.refalt != nil {
+		ra := parsers.RefAltInterval{Interval: *g, HasEnd: tbx.hasEnd()}
+		ra.SetRefAlt(tbx.refalt)
+		return &ra
+	}
+	return g
+}
+
+// hasEnd returns true if the end of a record is given by a column rather
+// than by the length of its ref.
+func (tbx *Bix) hasEnd() bool {
+	return (tbx.EndColumn() != 0 && tbx.EndColumn() != tbx.BeginColumn()) || tbx.lengthColumn != 0
+}
+
+func unsafeString(b []byte) string {
+	return *(*string)(unsafe.Pointer(&b))
+}
+
+// return an interval using the info from the tabix index. If lengthCol is
+// not -1, the end is the start plus the value in that column.
+func newgeneric(fields [][]byte, chromCol int, startCol int, endCol int, lengthCol int, zeroBased bool) (*parsers.Interval, error) {
+	s, e, err := genericBounds(fields, startCol, endCol, lengthCol, zeroBased)
+	if err != nil {
+		return nil, err
+	}
+	return parsers.NewInterval(string(fields[chromCol]), uint32(s), uint32(e), fields, uint32(0), nil), nil
+}
+
+// fieldStart returns the 0-based start from the 0-based column startCol.
+func fieldStart(fields [][]byte, startCol int, zeroBased bool) (int, error) {
+	s, err := strconv.Atoi(unsafeString(fields[startCol]))
+	if err != nil {
+		return 0, err
+	}
+	if !zeroBased {
+		s -= 1
+	}
+	return s, nil
+}
+
+// NormalizedStart returns the 0-based start of r, a record from tbx,
+// computed from its columns with the file's begin column and zero-based
+// setting. For VCF and for records without their fields, it is r.Start().
+func (tbx *Bix) NormalizedStart(r interfaces.Relatable) (int, error) {
+	s, _, err := tbx.normalized(r)
+	return s, err
+}
+
+// NormalizedEnd returns the 0-based, exclusive end of r, a record from tbx,
+// computed from its columns as for NormalizedStart. Files without an end or
+// length column have single-base records.
+func (tbx *Bix) NormalizedEnd(r interfaces.Relatable) (int, error) {
+	_, e, err := tbx.normalized(r)
+	return e, err
+}
+
+func (tbx *Bix) normalized(r interfaces.Relatable) (int, int, error) {
+	fields := recordFields(r)
+	if tbx.IsVCF() || fields == nil {
+		return int(r.Start()), int(r.End()), nil
+	}
+	if len(fields) < tbx.BeginColumn() || len(fields) < tbx.EndColumn() || len(fields) < tbx.lengthColumn {
+		return 0, 0, errors.Errorf("bix: record has %d fields; too few for the columns of %s", len(fields), tbx.path)
+	}
+	s, e, err := genericBounds(fields, tbx.BeginColumn()-1, tbx.EndColumn()-1, tbx.lengthColumn-1, tbx.ZeroBased())
+	if err != nil {
+		return 0, 0, errors.Wrapf(err, "bix: error parsing coordinates from %s", tbx.path)
+	}
+	return s, e, nil
+}
+
+// recordFields returns the fields of a record that is not VCF, or nil.
+func recordFields(r interfaces.Relatable) [][]byte {
+	switch v := r.(type) {
+	case *parsers.Interval:
+		return v.Fields
+	case *parsers.RefAltInterval:
+		return v.Fields
+	case *rawInterval:
+		return v.Fields
+	case *rawRefAltInterval:
+		return v.Fields
+	}
+	return nil
+}
+
+// RecordKey returns a key for r that is the same for identical records, so
+// that records found more than once, as from overlapping regions or several
+// files, can be removed. For VCF and other records with ref and alt, it is
+// chrom:pos:ref:alt with the 1-based position and the alts joined by commas.
+// For other records it is chrom:start:end, 0-based and half-open, followed
+// by a hash of all of the fields of the record, if it has them. The key of a
+// record from QueryClipped is that of the record before clipping.
+func RecordKey(r interfaces.Relatable) string {
+	if c, ok := r.(*ClippedRecord); ok {
+		r = c.Record()
+	}
+	if ra, ok := r.(interfaces.IRefAlt); ok {
+		return fmt.Sprintf("%s:%d:%s:%s", r.Chrom(), r.Start()+1, ra.Ref(), strings.Join(ra.Alt(), ","))
+	}
+	fields := recordFields(r)
+	if fields == nil {
+		return fmt.Sprintf("%s:%d:%d", r.Chrom(), r.Start(), r.End())
+	}
+	h := fnv.New64a()
+	for _, f := range fields {
+		h.Write(f)
+		// the separator keeps the hash of "a", "bc" from that of "ab", "c".
+		h.Write([]byte{0})
+	}
+	return fmt.Sprintf("%s:%d:%d:%016x", r.Chrom(), r.Start(), r.End(), h.Sum64())
+}
+
+// genericBounds returns the 0-based start and end of the record in fields.
+// If there is no end or length column (both are -1), the record covers a
+// single base.
+func genericBounds(fields [][]byte, startCol int, endCol int, lengthCol int, zeroBased bool) (int, int, error) {
+	s, err := fieldStart(fields, startCol, zeroBased)
+	if err != nil {
+		return 0, 0, err
+	}
+	var e int
+	if lengthCol != -1 {
+		l, err := strconv.Atoi(unsafeString(fields[lengthCol]))
+		if err != nil {
+			return 0, 0, err
+		}
+		e = s + l
+	} else if endCol == -1 {
+		e = s + 1
+	} else {
+		e, err = strconv.Atoi(unsafeString(fields[endCol]))
+		if err != nil {
+			return 0, 0, err
+		}
+	}
+	return s, e, nil
+}
+
+// CanonicalContig returns the name of chrom as it is stored in the index,
+// allowing for a missing or extra "chr" prefix and for the aliases set with
+// SetContigAliases. The bool is false if the contig is not present.
+func (tbx *Bix) CanonicalContig(chrom string) (string, bool) {
+	return aliasedContig(tbx.Index.Names(), tbx.aliases, chrom)
+}
+
+// SetContigAliases sets other names for the contigs of the file, such as
+// "1" for "NC_000001.11", that are used by queries. aliases maps each alias
+// to the name in the file. A name that is in the index is used as is; an
+// alias is looked up with and without a "chr" prefix.
+func (tbx *Bix) SetContigAliases(aliases map[string]string) {
+	tbx.aliases = aliases
+}
+
+// ContigStyle is a naming convention for the contigs of returned records.
+type ContigStyle int
+
+const (
+	// KeepContigs leaves contig names as they are in the file.
+	KeepContigs ContigStyle = iota
+	// WithChr adds a "chr" prefix to contig names that do not have one.
+	WithChr
+	// WithoutChr removes the "chr" prefix from contig names.
+	WithoutChr
+)
+
+// SetOutputContigStyle sets the naming of the contigs of the records that
+// queries return, such as WithChr to give "chr1" for "1" in the file. Only
+// the "chr" prefix is changed, so "MT" becomes "chrMT" rather than "chrM".
+// The raw lines from QueryRaw, CopyRegion and SetRawLines are not changed.
+// Queries accept either style regardless; see CanonicalContig.
+func (tbx *Bix) SetOutputContigStyle(style ContigStyle) {
+	tbx.contigStyle = style
+}
+
+// styleContig rewrites the contig in toks in the style set with
+// SetOutputContigStyle.
+func (tbx *Bix) styleContig(toks [][]byte) {
+	c := tbx.NameColumn() - 1
+	switch tbx.contigStyle {
+	case WithChr:
+		if !bytes.HasPrefix(toks[c], []byte("chr")) {
+			toks[c] = append([]byte("chr"), toks[c]...)
+		}
+	case WithoutChr:
+		toks[c] = bytes.TrimPrefix(toks[c], []byte("chr"))
+	}
+}
+
+// aliasedContig is canonicalContig with a fallback to aliases.
+func aliasedContig(names []string, aliases map[string]string, chrom string) (string, bool) {
+	if name, ok := canonicalContig(names, chrom); ok || len(aliases) == 0 {
+		return name, ok
+	}
+	for _, a := range []string{chrom, stripChr(chrom), "chr" + stripChr(chrom)} {
+		if name, ok := aliases[a]; ok {
+			return canonicalContig(names, name)
+		}
+	}
+	return "", false
+}
+
+func canonicalContig(names []string, chrom string) (string, bool) {
+	for _, name := range names {
+		if name == chrom {
+			return name, true
+		}
+	}
+	chrom = stripChr(chrom)
+	for _, name := range names {
+		if stripChr(name) == chrom {
+			return name, true
+		}
+	}
+	return "", false
+}
+
+// ErrUnknownContig is the cause of the error returned when querying a
+// contig that is not in the index if strict mode is on. See SetStrict.
+var ErrUnknownContig = errors.New("bix: unknown contig")
+
+// SetStrict determines how queries on a contig that is not in the index are
+// handled. If strict is true, they return an error with ErrUnknownContig as
+// its cause, otherwise (the default) a warning is logged and no records are
+// returned.
+func (tbx *Bix) SetStrict(strict bool) {
+	tbx.strict = strict
+}
+
+func (tbx *Bix) ChunkedReader(chrom string, start, end int) (io.ReadCloser, error) {
+	chunks, err := tbx.regionChunks(chrom, start, end)
+	if err != nil {
+		return nil, err
+	}
+	if err := tbx.init(); err != nil {
+		return nil, err
+	}
+	cr, err := newChunkReader(tbx.bgzf, chunks)
+	if err != nil {
+		return nil, errors.Wrapf(err, "bix: error creating chunked reader from %s", tbx.path)
+	}
+	return cr, nil
+}
+
+// regionChunks returns the chunks of the file that hold the records in the
+// region. A contig that is not in the index has no chunks unless strict mode
+// is on.
+func (tbx *Bix) regionChunks(chrom string, start, end int) ([]bgzf.Chunk, error) {
+	name, ok := tbx.CanonicalContig(chrom)
+	if !ok {
+		if tbx.strict {
+			return nil, errors.Wrapf(ErrUnknownContig, "%s not found in %s", chrom, tbx.path)
+		}
+		log.Printf("chromosome %s not found in %s\n", chrom, tbx.path)
+		return nil, nil
+	}
+	chunks, err := tbx.Chunks(name, start, end)
+	if err == index.ErrInvalid || err == index.ErrNoReference {
+		return nil, nil
+	} else if err != nil {
+		return nil, errors.Wrapf(err, "bix: error reading Chunks from %s", tbx.path)
+	}
+	return chunks, nil
+}
+
+// ChunkInfo returns the number of chunks that a query on the 0-based,
+// half-open region would read and the number of compressed bytes in the
+// blocks that they span, from the start of the block holding the start of
+// each chunk to the end of the block holding its end. Only the index and the
+// header of the last block of each chunk are read, so this is a cheap
+// estimate of the cost of the query.
+func (tbx *Bix) ChunkInfo(chrom string, start, end int) (nChunks int, compressedBytes int64, err error) {
+	chunks, err := tbx.regionChunks(chrom, start, end)
+	if err != nil || len(chunks) == 0 {
+		return 0, 0, err
+	}
+	f, err := openData(tbx.path)
+	if err != nil {
+		return 0, 0, errors.Wrapf(err, "bix: error opening %s", tbx.path)
+	}
+	defer f.Close()
+	for _, c := range chunks {
+		compressedBytes += c.End.File - c.Begin.File
+		// a chunk that ends at the start of a block does not read it.
+		if c.End.Block == 0 {
+			continue
+		}
+		size, err := tbx.blockSizeAt(f, c.End.File)
+		if err == io.EOF {
+			return 0, 0, errors.Errorf("bix: chunk ends past the end of %s", tbx.path)
+		} else if err != nil {
+			return 0, 0, err
+		}
+		compressedBytes += size
+	}
+	return len(chunks), compressedBytes, nil
+}
+
+// MightOverlap returns false if the index shows that there are no records in
+// the 0-based, half-open region, so a query on it can be skipped. Only the
+// index is read. A true result means only that the region shares bins with
+// some records; a query may still return none. A contig that is not in the
+// index has no records unless strict mode is on, in which case an error is
+// returned.
+func (tbx *Bix) MightOverlap(chrom string, start, end int) (bool, error) {
+	chunks, err := tbx.regionChunks(chrom, start, end)
+	return len(chunks) > 0, err
+}
+
+// Peeker is an interfaces.RelatableIterator that can return the next record
+// without consuming it. The iterators returned by Query and FastQuery are
+// Peekers.
+type Peeker interface {
+	interfaces.RelatableIterator
+	// Peek returns the record that the following call to Next will return.
+	Peek() (interfaces.Relatable, error)
+}
+
+// bixerator meets interfaces.RelatableIterator
+type bixerator struct {
+	rdr io.ReadCloser
+	buf *bufio.Reader
+	tbx *Bix
+
+	region interfaces.IPosition
+
+	// CRC32 of the compressed bytes read; nil if not enabled.
+	crc hash.Hash32
+
+	// a record read by Peek that has not yet been returned by Next.
+	peeked     bool
+	peekedRec  interfaces.Relatable
+	peekedLine []byte
+	peekedErr  error
+
+	// if non-nil, only lines for which filter returns true are parsed.
+	filter func([][]byte) bool
+
+	// buffers used if tbx.reuse is set and keep is not.
+	keep     bool
+	line     []byte
+	fields   [][]byte
+	interval parsers.Interval
+	refAlt   parsers.RefAltInterval
+
+	// the leading fields of a VCF line, used to check its bounds without
+	// splitting the rest of the line.
+	head [8][]byte
+
+	closed bool
+}
+
+// split returns the fields of line.
+func (tbx *Bix) split(line []byte) [][]byte {
+	if tbx.VReader != nil {
+		return makeFields(line, tbx.delim)
+	}
+	if tbx.quoted {
+		return splitQuoted(nil, line, tbx.delim)
+	}
+	return bytes.Split(line, []byte{tbx.delim})
+}
+
+// splitQuoted appends the fields of line to dst, ignoring delimiters within
+// double quotes.
+func splitQuoted(dst [][]byte, line []byte, delim byte) [][]byte {
+	quoted, start := false, 0
+	for i, c := range line {
+		switch c {
+		case '"':
+			quoted = !quoted
+		case delim:
+			if !quoted {
+				dst = append(dst, line[start:i])
+				start = i + 1
+			}
+		}
+	}
+	return append(dst, line[start:])
+}
+
+// reusing returns true if the line, fields and record are reused.
+func (b *bixerator) reusing() bool {
+	return b.tbx.reuse && !b.keep && b.tbx.VReader == nil
+}
+
+// split returns the fields of line, reusing the fields of the previous line
+// if possible.
+func (b *bixerator) split(line []byte) [][]byte {
+	if !b.reusing() {
+		return b.tbx.split(line)
+	}
+	if b.tbx.quoted {
+		b.fields = splitQuoted(b.fields[:0], line, b.tbx.delim)
+		return b.fields
+	}
+	b.fields = b.fields[:0]
+	for {
+		i := bytes.IndexByte(line, b.tbx.delim)
+		if i == -1 {
+			break
+		}
+		b.fields = append(b.fields, line[:i])
+		line = line[i+1:]
+	}
+	b.fields = append(b.fields, line)
+	return b.fields
+}
+
+// readLine returns the next line including the newline, reusing the buffer
+// of the previous line if possible.
+func (b *bixerator) readLine() ([]byte, error) {
+	if !b.reusing() && b.tbx.maxLine == 0 {
+		return b.buf.ReadBytes('\n')
+	}
+	var line []byte
+	if b.reusing() {
+		line = b.line[:0]
+	}
+	line, err := readLine(b.buf, line, b.tbx.maxLine)
+	if err == ErrLineTooLong {
+		return nil, errors.Wrapf(err, "longer than %d bytes in block at offset %d", b.tbx.maxLine, b.tbx.bgzf.LastChunk().Begin.File)
+	}
+	if b.reusing() {
+		b.line = line
+	}
+	return line, err
+}
+
+func makeFields(line []byte, delim byte) [][]byte {
+	fields := make([][]byte, 9)
+	copy(fields[:8], bytes.SplitN(line, []byte{delim}, 8))
+	s := 0
+	for i, f := range fields {
+		if i == 7 {
+			break
+		}
+		s += len(f) + 1
+	}
+	/*
+		if s >= len(line) {
+			c := make([]string, len(fields))
+			for k, f := range fields {
+				c[k] = string(f)
+			}
+			log.Println(s, string(line), c)
+		}
+	*/
+	e := bytes.IndexByte(line[s:], delim)
+	if e == -1 {
+		e = len(line)
+	} else {
+		e += s
+	}
+
+	fields[7] = line[s:e]
+	if len(line) > e+1 {
+		fields[8] = line[e+1:]
+	} else {
+		fields = fields[:8]
+	}
+
+	return fields
+}
+
+func (b *bixerator) Next() (interfaces.Relatable, error) {
+	v, _, err := b.next()
+	return v, err
+}
+
+func (b *bixerator) Peek() (interfaces.Relatable, error) {
+	if !b.peeked {
+		b.peekedRec, b.peekedLine, b.peekedErr = b.read()
+		b.peeked = true
+	}
+	return b.peekedRec, b.peekedErr
+}
+
+// next returns the next record along with the raw line (including any
+// line terminator) that it was parsed from.
+func (b *bixerator) next() (interfaces.Relatable, []byte, error) {
+	if b.peeked {
+		b.peeked = false
+		return b.peekedRec, b.peekedLine, b.peekedErr
+	}
+	return b.read()
+}
+
+func (b *bixerator) read() (interfaces.Relatable, []byte, error) {
+	toks, raw, err := b.readToks()
+	if err != nil {
+		return nil, nil, err
+	}
+	b.tbx.styleContig(toks)
+	var v interfaces.Relatable
+	if b.reusing() {
+		tbx := b.tbx
+		s, e, err := genericBounds(toks, tbx.BeginColumn()-1, tbx.EndColumn()-1, tbx.lengthColumn-1, tbx.ZeroBased())
+		if err != nil {
+			return nil, nil, errors.Wrapf(err, "bix: error parsing line from %s", tbx.path)
+		}
+		chrom := unsafeString(toks[tbx.NameColumn()-1])
+		b.interval = *parsers.NewInterval(chrom, uint32(s), tbx.clampEnd(chrom, uint32(s), uint32(e)), toks, 0, nil)
+		v = &b.interval
+		if tbx.refalt != nil {
+			b.refAlt = parsers.RefAltInterval{Interval: b.interval, HasEnd: tbx.hasEnd()}
+			b.refAlt.SetRefAlt(tbx.refalt)
+			v = &b.refAlt
+		}
+	} else {
+		v = b.tbx.toPosition(toks)
+	}
+	if b.tbx.rawLines {
+		v = withRaw(v, raw)
+	}
+	return v, raw, nil
+}
+
+// readToks returns the fields of the next line in the region and the raw
+// line they were split from without parsing them into a record.
+func (b *bixerator) readToks() ([][]byte, []byte, error) {
+
+	for {
+		line, err := b.readLine()
+
+		// a final line without a newline is returned along with io.EOF.
+		if err == io.EOF && len(line) == 0 {
+			return nil, nil, io.EOF
+		} else if err != nil && err != io.EOF {
+			return nil, nil, errors.Wrapf(err, "bix: error iterating on %s", b.tbx.path)
+		}
+		if len(line) == 0 {
+			return nil, nil, io.EOF
+		}
+		raw := line
+		if line[len(line)-1] == '\n' {
+			line = line[:len(line)-1]
+		}
+		if len(line) > 0 && line[len(line)-1] == '\r' {
+			line = line[:len(line)-1]
+		}
+		if len(line) == 0 || rune(line[0]) == b.tbx.MetaChar() || (b.tbx.lineFilter != nil && b.tbx.lineFilter(line)) {
+			// skip blank and filtered lines and header lines, which may be
+			// repeated in the middle of concatenated files.
+			continue
+		}
+		in := true
+		var toks [][]byte
+		if b.region != nil {
+			var err error
+
+			if b.tbx.quickVCF() {
+				in, err, toks = b.vcfInBounds(line)
+			} else {
+				in, err, toks = b.inBounds(line)
+			}
+			if err != nil {
+				return nil, nil, err
+			}
+		} else {
+			toks = b.split(line)
+		}
+
+		if in && b.tbx.strand != 0 {
+			in = len(toks) >= b.tbx.strandColumn && len(toks[b.tbx.strandColumn-1]) == 1 &&
+				toks[b.tbx.strandColumn-1][0] == b.tbx.strand
+		}
+		if in && b.filter != nil {
+			in = b.filter(toks)
+		}
+
+		if in {
+			return toks, raw, nil
+		}
+	}
+}
+
+func (b *bixerator) Checksum() uint32 {
+	if b.crc == nil {
+		return 0
+	}
+	return b.crc.Sum32()
+}
+
+func (b *bixerator) Close() error {
+	if b.closed {
+		return nil
+	}
+	b.closed = true
+	if b.rdr != nil {
+		b.rdr.Close()
+	}
+	if b.tbx.serial && b.crc != nil {
+		// the shared bgzf reader was replaced by one that updates the checksum.
+		b.tbx.closeFile()
+	}
+	return b.tbx.release()
+}
+
+var _ Peeker = (*bixerator)(nil)
+var _ Checksummer = (*bixerator)(nil)
+
+// SetInclusiveEnd determines whether the End() of query regions is treated
+// as inclusive. By default, regions are 0-based and half-open, so a record
+// starting at End() is not returned. With inclusive ends, it is, which matches
+// the 1-based, inclusive region syntax of samtools and bcftools when Start()
+// has been converted to 0-based.
+func (tbx *Bix) SetInclusiveEnd(inclusive bool) {
+	tbx.inclusiveEnd = inclusive
+}
+
+// SetBookended determines whether book-ended records, those that end
+// exactly at the Start() of a query region or start exactly at its End(), are
+// returned. By default they are not: as for bedtools intersect, a record must
+// share at least one base with the region, where both are 0-based and
+// half-open. The ends of records in 1-based files are already exclusive once
+// their start is converted to 0-based, so the same rule applies to them. A
+// zero-length record is a point at its start and is returned by default if
+// the region includes that position.
+func (tbx *Bix) SetBookended(include bool) {
+	tbx.bookended = include
+}
+
+// regionStart returns the position that records must end after to be
+// returned for region.
+func (tbx *Bix) regionStart(region interfaces.IPosition) int {
+	return int(inclusiveStart(region, tbx.bookended))
+}
+
+// regionEnd returns the exclusive end of region.
+func (tbx *Bix) regionEnd(region interfaces.IPosition) int {
+	return int(exclusiveEnd(region, tbx.inclusiveEnd, tbx.bookended))
+}
+
+// inclusiveStart returns the position that records must end after to overlap
+// region, one less than its start if book-ended records are included.
+func inclusiveStart(region interfaces.IPosition, bookended bool) uint32 {
+	if bookended && region.Start() > 0 {
+		return region.Start() - 1
+	}
+	return region.Start()
+}
+
+// exclusiveEnd returns the exclusive end of region. A zero-width region, such
+// as an insertion point, is treated as covering the single base at its start
+// so that the records spanning the point are found.
+func exclusiveEnd(region interfaces.IPosition, inclusive, bookended bool) uint32 {
+	end := region.End()
+	if inclusive || region.Start() == region.End() {
+		end++
+	}
+	if bookended {
+		end++
+	}
+	return end
+}
+
+// checkRegion returns an error if region has a start greater than its end or
+// a start that is likely a negative value that has wrapped to a uint32.
+func checkRegion(region interfaces.IPosition) error {
+	if region.Start() > region.End() {
+		return errors.Errorf("bix: invalid region %s:%d-%d: start is greater than end", region.Chrom(), region.Start(), region.End())
+	}
+	if region.Start() > math.MaxInt32 {
+		return errors.Errorf("bix: invalid region %s:%d-%d: start is likely negative", region.Chrom(), region.Start(), region.End())
+	}
+	return nil
+}
+
+// FastQuery allows extracting intervals from an indexed file. Use this function if
+// concurrency is *not* required, otherwise use Query
+func (tbx *Bix) FastQuery(region interfaces.IPosition) (interfaces.RelatableIterator, error) {
+	if err := checkRegion(region); err != nil {
+		return nil, err
+	}
+	if tbx.serial || tbx.multi != nil {
+		return tbx.Query(region)
+	}
+	if err := tbx.init(); err != nil {
+		return nil, err
+	}
+	cr, err := tbx.ChunkedReader(region.Chrom(), tbx.regionStart(region), tbx.regionEnd(region))
+	if err != nil {
+		if cr != nil {
+			cr.Close()
+			tbx.closeFile()
+		}
+		return nil, err
+	}
+	tbx.fastQuery = true
+	return &bixerator{rdr: cr, buf: tbx.newReader(cr), tbx: tbx, region: region}, nil
+}
+
+// Query allows extracting intervals from an indexed file. Use this function if
+// concurrency is required, otherwise use FastQuery. Each call uses its own
+// file handle and VCF parser so iterators from concurrent calls do not
+// interfere. Headers should not be modified (e.g. with AddInfoToHeader) while
+// queries are running.
+// A nil region scans the entire file, reading past the EOF markers of
+// concatenated bgzf files; this works even if such a file has not been
+// re-indexed.
+// A region with Start() equal to End(), such as the position of an insertion,
+// returns the records that overlap the base at Start(). A zero-length record,
+// such as a point feature in BED, is returned by regions that include its
+// start. Records that only touch the region are not returned unless
+// SetBookended(true) has been called.
+// If tbx is from NewSerial, queries share a file handle; see NewSerial.
+func (tbx *Bix) Query(region interfaces.IPosition) (interfaces.RelatableIterator, error) {
+	if tbx.closed {
+		return nil, errors.Wrap(ErrClosed, tbx.path)
+	}
+	if region != nil {
+		if err := checkRegion(region); err != nil {
+			return nil, err
+		}
+	}
+	if tbx.multi != nil {
+		return tbx.queryMulti(region)
+	}
+	if tbx.stream != nil {
+		return tbx.queryStream(region)
+	}
+	tbx2, err := tbx.acquire()
+	if err != nil {
+		return nil, err
+	}
+	return tbx2.query(region)
+}
+
+// query is Query on a Bix from acquire.
+func (tbx2 *Bix) query(region interfaces.IPosition) (interfaces.RelatableIterator, error) {
+	if region == nil {
+		var err error
+		var crc hash.Hash32
+		if tbx2.checksum {
+			// re-read from the start of the file so that every compressed
+			// byte passes through the checksum.
+			crc = crc32.NewIEEE()
+			tbx2.bgzf.Close()
+			if _, err = tbx2.file.Seek(0, io.SeekStart); err != nil {
+				tbx2.file.Close()
+				tbx2.bgzf, tbx2.file = nil, nil
+				tbx2.release()
+				return nil, errors.Wrapf(err, "bix: error seeking in %s", tbx2.path)
+			}
+			tbx2.bgzf, err = tbx2.openBlocks(io.TeeReader(tbx2.file, crc))
+			if err != nil {
+				tbx2.file.Close()
+				tbx2.bgzf, tbx2.file = nil, nil
+				tbx2.release()
+				return nil, err
 			}
+		} else if tbx2.serial {
+			if err = tbx2.bgzf.Seek(bgzf.Offset{}); err != nil {
+				tbx2.release()
+				return nil, errors.Wrapf(err, "bix: error seeking in %s", tbx2.path)
+			}
+		}
+		buf := tbx2.newReader(tbx2.bgzf)
+		_, l, err := readHeader(buf, tbx2.Index.Skip(), tbx2.Index.MetaChar())
+		if err != nil {
+			tbx2.release()
+			return nil, errors.Wrapf(err, "bix: error reading line from %s", tbx2.path)
 		}
-		if tbx2.Index.Skip() == 0 && rune(l[0]) != tbx2.Index.MetaChar() {
-			buf = bufio.NewReader(io.MultiReader(strings.NewReader(l), buf))
+		if l != "" {
+			// put back the first data line.
+			buf = tbx2.newReader(io.MultiReader(strings.NewReader(l), buf))
 		}
-		return bixerator{nil, buf, tbx2, region}, nil
+		return &bixerator{buf: buf, tbx: tbx2, region: region, crc: crc}, nil
 	}
 
-	cr, err := tbx2.ChunkedReader(region.Chrom(), int(region.Start()), int(region.End()))
+	cr, err := tbx2.ChunkedReader(region.Chrom(), tbx2.regionStart(region), tbx2.regionEnd(region))
 	if err != nil {
-		if cr != nil {
-			cr.Close()
-			tbx2.Close()
+		tbx2.release()
+		return nil, err
+	}
+	return &bixerator{rdr: cr, buf: tbx2.newReader(cr), tbx: tbx2, region: region}, nil
+}
+
+// queryBixerator is Query for the methods that need the underlying
+// bixerator, which is not available for a query that spans several files
+// from NewMulti.
+func (tbx *Bix) queryBixerator(region interfaces.IPosition) (*bixerator, error) {
+	it, err := tbx.Query(region)
+	if err != nil {
+		return nil, err
+	}
+	bx, ok := it.(*bixerator)
+	if !ok {
+		it.Close()
+		return nil, errMulti
+	}
+	return bx, nil
+}
+
+// QueryFilter is like Query but only the lines for which keep returns true
+// are parsed and returned, so uninteresting lines are skipped cheaply. keep
+// is passed the fields of the line; for VCF these are the first 8 columns
+// followed by the remainder of the line, if any, as a single field. The
+// fields are only valid during the call.
+func (tbx *Bix) QueryFilter(region interfaces.IPosition, keep func(toks [][]byte) bool) (interfaces.RelatableIterator, error) {
+	bx, err := tbx.queryBixerator(region)
+	if err != nil {
+		return nil, err
+	}
+	bx.filter = keep
+	return bx, nil
+}
+
+// ResumeFrom returns an iterator over the records from the virtual offset
+// off to the end of the file, so that a long scan can be restarted from a
+// saved position. off must be the offset of the start of a line. An offset of
+// zero is the same as Query(nil), which skips the header.
+func (tbx *Bix) ResumeFrom(off bgzf.Offset) (interfaces.RelatableIterator, error) {
+	if off == (bgzf.Offset{}) {
+		return tbx.Query(nil)
+	}
+	tbx2, err := tbx.acquire()
+	if err != nil {
+		return nil, err
+	}
+	if err := tbx2.bgzf.Seek(off); err != nil {
+		tbx2.release()
+		return nil, errors.Wrapf(err, "bix: error seeking to %d:%d in %s", off.File, off.Block, tbx2.path)
+	}
+	return &bixerator{buf: tbx2.newReader(tbx2.bgzf), tbx: tbx2}, nil
+}
+
+// SetFeatureColumn sets the 1-based column holding the names of features
+// that QueryName matches. The default is the ID column for VCF and the name
+// column, 4, for BED and other files.
+func (tbx *Bix) SetFeatureColumn(col int) {
+	tbx.featureColumn = col
+}
+
+// QueryName returns an iterator over the records within flank bases of the
+// first record on chrom whose name is name, including that record. See
+// SetFeatureColumn. The contig is scanned to find the record, so this is
+// slower than a query by position.
+func (tbx *Bix) QueryName(chrom, name string, flank int) (interfaces.RelatableIterator, error) {
+	if flank < 0 {
+		return nil, errors.Errorf("bix: invalid flank %d", flank)
+	}
+	col := tbx.featureColumn
+	if col == 0 {
+		col = 4
+		if tbx.IsVCF() {
+			col = 3
+		}
+	}
+	bx, err := tbx.queryBixerator(interfaces.AsIPosition(chrom, 0, maxEnd))
+	if err != nil {
+		return nil, err
+	}
+	bx.filter = func(toks [][]byte) bool {
+		return len(toks) >= col && string(toks[col-1]) == name
+	}
+	v, err := bx.Next()
+	if err != nil {
+		bx.Close()
+		if err == io.EOF {
+			return nil, errors.Errorf("bix: no record named %s on %s in %s", name, chrom, tbx.path)
+		}
+		return nil, err
+	}
+	// copy what is needed before Close, as the record may share buffers.
+	found, start, end := strings.Clone(v.Chrom()), int(v.Start())-flank, int(v.End())+flank
+	bx.Close()
+	if start < 0 {
+		start = 0
+	}
+	if end > maxEnd {
+		end = maxEnd
+	}
+	return tbx.Query(interfaces.AsIPosition(found, start, end))
+}
+
+// QueryChrom returns an iterator over all records on chrom, allowing for a
+// missing or extra "chr" prefix. A contig that is not in the index gives an
+// empty iterator unless strict mode is on. See SetStrict.
+func (tbx *Bix) QueryChrom(chrom string) (interfaces.RelatableIterator, error) {
+	return tbx.Query(interfaces.AsIPosition(chrom, 0, maxEnd))
+}
+
+// Genome returns an iterator over the records on every contig, in the order
+// that the contigs appear in the index. Unlike a Query with a nil region, it
+// reads only indexed data, but it does so with a single file handle.
+func (tbx *Bix) Genome() (interfaces.RelatableIterator, error) {
+	tbx2, err := tbx.acquire()
+	if err != nil {
+		return nil, err
+	}
+	return &genomeIterator{tbx: tbx2, names: tbx2.Index.Names()}, nil
+}
+
+// genomeIterator iterates over each contig in names in turn.
+type genomeIterator struct {
+	tbx    *Bix
+	names  []string
+	cur    *bixerator
+	closed bool
+}
+
+func (g *genomeIterator) Next() (interfaces.Relatable, error) {
+	for {
+		if g.cur != nil {
+			v, err := g.cur.Next()
+			if err != io.EOF {
+				return v, err
+			}
+			g.cur.rdr.Close()
+			g.cur = nil
+		}
+		if len(g.names) == 0 {
+			return nil, io.EOF
+		}
+		region := interfaces.AsIPosition(g.names[0], 0, maxEnd)
+		g.names = g.names[1:]
+		cr, err := g.tbx.ChunkedReader(region.Chrom(), 0, maxEnd)
+		if err != nil {
+			return nil, err
+		}
+		g.cur = &bixerator{rdr: cr, buf: g.tbx.newReader(cr), tbx: g.tbx, region: region}
+	}
+}
+
+func (g *genomeIterator) Close() error {
+	if g.closed {
+		return nil
+	}
+	g.closed = true
+	if g.cur != nil {
+		g.cur.rdr.Close()
+	}
+	return g.tbx.release()
+}
+
+// ScanByContig reads the file once, calling fn for each contig in the order
+// of the file with an iterator over the records on it, for per-contig
+// reductions. Records of the contig that fn does not read are skipped. The
+// iterator is only valid during the call and closing it is optional. An
+// error from fn stops the scan and is returned. A contig that is not
+// together in the file is passed to fn once for each of its runs.
+func (tbx *Bix) ScanByContig(fn func(chrom string, it interfaces.RelatableIterator) error) error {
+	bx, err := tbx.queryBixerator(nil)
+	if err != nil {
+		return err
+	}
+	defer bx.Close()
+	for {
+		v, err := bx.Peek()
+		if err == io.EOF {
+			return nil
+		} else if err != nil {
+			return err
+		}
+		// the record may be reused, and its chrom with it.
+		it := &contigIterator{bx: bx, chrom: strings.Clone(v.Chrom())}
+		if err := fn(it.chrom, it); err != nil {
+			return err
+		}
+		for {
+			if _, err := it.next(); err == io.EOF {
+				break
+			} else if err != nil {
+				return err
+			}
+		}
+	}
+}
+
+// contigIterator returns the records of a scan up to the end of a contig.
+type contigIterator struct {
+	bx     *bixerator
+	chrom  string
+	closed bool
+}
+
+func (c *contigIterator) Next() (interfaces.Relatable, error) {
+	if c.closed {
+		return nil, io.EOF
+	}
+	return c.next()
+}
+
+// next returns the next record on the contig, even if c is closed.
+func (c *contigIterator) next() (interfaces.Relatable, error) {
+	v, err := c.bx.Peek()
+	if err != nil {
+		return nil, err
+	}
+	if v.Chrom() != c.chrom {
+		return nil, io.EOF
+	}
+	return c.bx.Next()
+}
+
+func (c *contigIterator) Close() error {
+	c.closed = true
+	return nil
+}
+
+// CopyRegion writes the original, unmodified line for each record in region
+// for which keep returns true. If keep is nil, all records are written. To
+// write bgzf output, pass a *bgzf.Writer as w. It returns the number of
+// records written.
+func (tbx *Bix) CopyRegion(w io.Writer, region interfaces.IPosition, keep func(interfaces.Relatable) bool) (int, error) {
+	bx, err := tbx.queryBixerator(region)
+	if err != nil {
+		return 0, err
+	}
+	defer bx.Close()
+
+	n := 0
+	for {
+		v, line, err := bx.next()
+		if err == io.EOF {
+			return n, nil
+		} else if err != nil {
+			return n, err
+		}
+		if keep != nil && !keep(v) {
+			continue
+		}
+		if _, err := w.Write(line); err != nil {
+			return n, errors.Wrapf(err, "bix: error writing record from %s", tbx.path)
+		}
+		n++
+	}
+}
+
+// QueryN is like Query but the returned iterator stops after n records,
+// closing the underlying reader when the limit is reached.
+func (tbx *Bix) QueryN(region interfaces.IPosition, n int) (interfaces.RelatableIterator, error) {
+	it, err := tbx.Query(region)
+	if err != nil {
+		return nil, err
+	}
+	return &limitIterator{RelatableIterator: it, n: n}, nil
+}
+
+type limitIterator struct {
+	interfaces.RelatableIterator
+	n      int
+	closed bool
+}
+
+func (l *limitIterator) Next() (interfaces.Relatable, error) {
+	if l.n <= 0 {
+		l.Close()
+		return nil, io.EOF
+	}
+	l.n--
+	return l.RelatableIterator.Next()
+}
+
+func (l *limitIterator) Close() error {
+	if l.closed {
+		return nil
+	}
+	l.closed = true
+	return l.RelatableIterator.Close()
+}
+
+// At returns an iterator over the records that start exactly at the 0-based
+// position pos on chrom. It reads only the chunks for [pos, pos+1) and stops
+// at the first record that starts after pos.
+func (tbx *Bix) At(chrom string, pos int) (interfaces.RelatableIterator, error) {
+	if pos < 0 || pos >= maxEnd {
+		return nil, errors.Errorf("bix: invalid position %s:%d", chrom, pos)
+	}
+	it, err := tbx.Query(interfaces.AsIPosition(chrom, pos, pos+1))
+	if err != nil {
+		return nil, err
+	}
+	return &atIterator{RelatableIterator: it, pos: uint32(pos)}, nil
+}
+
+type atIterator struct {
+	interfaces.RelatableIterator
+	pos uint32
+}
+
+func (a *atIterator) Next() (interfaces.Relatable, error) {
+	for {
+		v, err := a.RelatableIterator.Next()
+		if err != nil {
+			return nil, err
+		}
+		if v.Start() == a.pos {
+			return v, nil
 		}
+		// records are sorted by start so none of the rest can match.
+		if v.Start() > a.pos {
+			a.Close()
+			return nil, io.EOF
+		}
+	}
+}
+
+// QueryReverse is like Query but returns the records in region in
+// descending order of start. All of the records are read into memory before
+// the first is returned, so it is not suitable for very large regions.
+func (tbx *Bix) QueryReverse(region interfaces.IPosition) (interfaces.RelatableIterator, error) {
+	bx, err := tbx.queryBixerator(region)
+	if err != nil {
 		return nil, err
 	}
-	return bixerator{cr, bufio.NewReader(cr), tbx2, region}, nil
+	defer bx.Close()
+	// the records are kept, so they must not share buffers.
+	bx.keep = true
+
+	var recs []interfaces.Relatable
+	for {
+		v, err := bx.Next()
+		if err == io.EOF {
+			break
+		} else if err != nil {
+			return nil, err
+		}
+		recs = append(recs, v)
+	}
+	for i, j := 0, len(recs)-1; i < j; i, j = i+1, j-1 {
+		recs[i], recs[j] = recs[j], recs[i]
+	}
+	return &sliceIterator{recs: recs}, nil
+}
+
+// sliceIterator returns records from a slice.
+type sliceIterator struct {
+	recs []interfaces.Relatable
+}
+
+func (s *sliceIterator) Next() (interfaces.Relatable, error) {
+	if len(s.recs) == 0 {
+		return nil, io.EOF
+	}
+	v := s.recs[0]
+	s.recs = s.recs[1:]
+	return v, nil
+}
+
+func (s *sliceIterator) Close() error {
+	s.recs = nil
+	return nil
+}
+
+// Overlaps returns true if any record overlaps region. It stops reading at
+// the first overlapping record and does not parse it.
+func (tbx *Bix) Overlaps(region interfaces.IPosition) (bool, error) {
+	bx, err := tbx.queryBixerator(region)
+	if err != nil {
+		return false, err
+	}
+	defer bx.Close()
+	_, _, err = bx.readToks()
+	if err == io.EOF {
+		return false, nil
+	}
+	return err == nil, err
+}
+
+// IsVCF returns true if the file is VCF and records are parsed with VReader.
+// The INFO of a record is kept as bytes and a field is only parsed when it is
+// requested with Info().Get, so scans that do not use INFO do not pay for
+// parsing it. See BenchmarkVCFScan. The fixed columns, CHROM to INFO, are
+// found by name from the #CHROM line and must be the first 8 columns, though
+// not necessarily in the order of the specification.
+func (tbx *Bix) IsVCF() bool {
+	return tbx.VReader != nil
+}
+
+// ColumnNames returns the names of the columns from the header line of a
+// file that is not VCF, see SetHeaderLine, with the meta char removed from
+// the first, so that columns can be found by name. It returns nil for VCF
+// and for files without a header.
+func (tbx *Bix) ColumnNames() []string {
+	if tbx.columns == nil {
+		return nil
+	}
+	return append([]string(nil), tbx.columns...)
 }
 
 func (tbx *Bix) AddInfoToHeader(id, number, vtype, desc string) {
@@ -413,6 +2241,38 @@ func (tbx *Bix) AddInfoToHeader(id, number, vtype, desc string) {
 	tbx.VReader.AddInfoToHeader(id, number, vtype, desc)
 }
 
+// WriteVCFHeader writes the VCF header, including any INFO fields added with
+// AddInfoToHeader, to w. It returns an error if the file is not VCF.
+func (tbx *Bix) WriteVCFHeader(w io.Writer) error {
+	if tbx.VReader == nil {
+		return errors.Errorf("bix: %s is not VCF", tbx.path)
+	}
+	ew := &errWriter{w: w}
+	// vcfgo.NewWriter ignores write errors so they are kept by errWriter.
+	if _, err := vcfgo.NewWriter(ew, tbx.VReader.Header); err != nil {
+		return errors.Wrapf(err, "bix: error writing header for %s", tbx.path)
+	}
+	if ew.err != nil {
+		return errors.Wrapf(ew.err, "bix: error writing header for %s", tbx.path)
+	}
+	return nil
+}
+
+// errWriter records the first error from w and does not write after it.
+type errWriter struct {
+	w   io.Writer
+	err error
+}
+
+func (e *errWriter) Write(p []byte) (int, error) {
+	if e.err != nil {
+		return 0, e.err
+	}
+	n, err := e.w.Write(p)
+	e.err = err
+	return n, err
+}
+
 func (tbx *Bix) GetHeaderType(field string) string {
 	if tbx.VReader == nil {
 		return ""
@@ -444,74 +2304,132 @@ func (b *bixerator) inBounds(line []byte) (bool, error, [][]byte) {
 
 	var readErr error
 	line = bytes.TrimRight(line, "\r\n")
-	var toks [][]byte
-	if b.tbx.VReader != nil {
-		toks = makeFields(line)
-	} else {
-		toks = bytes.Split(line, []byte{'\t'})
-	}
+	toks := b.split(line)
 
-	s, err := strconv.Atoi(unsafeString(toks[b.tbx.BeginColumn()-1]))
+	pos, err := fieldStart(toks, b.tbx.BeginColumn()-1, b.tbx.ZeroBased())
 	if err != nil {
 		return false, err, toks
 	}
-
-	pos := s
-	if !b.tbx.ZeroBased() {
-		pos -= 1
-	}
-	if pos >= int(b.region.End()) {
+	if pos >= b.tbx.regionEnd(b.region) {
 		return false, io.EOF, toks
 	}
+	// records must end after lo to overlap the region.
+	lo := b.tbx.regionStart(b.region)
 
-	if b.tbx.EndColumn() != 0 {
-		e, err := strconv.Atoi(unsafeString(toks[b.tbx.EndColumn()-1]))
+	if b.tbx.lengthColumn != 0 {
+		l, err := strconv.Atoi(unsafeString(toks[b.tbx.lengthColumn-1]))
 		if err != nil {
 			return false, err, toks
 		}
-		if e < int(b.region.Start()) {
-			return false, readErr, toks
+		return pos+l > lo && b.tbx.inSpan(pos, pos+l), readErr, toks
+	} else if b.tbx.EndColumn() != 0 {
+		e, err := strconv.Atoi(unsafeString(toks[b.tbx.EndColumn()-1]))
+		if err != nil {
+			return false, err, toks
 		}
-		return true, readErr, toks
+		// a zero-length record is a point at its start.
+		in := e > lo || (e == pos && pos >= int(b.region.Start()))
+		return in && b.tbx.inSpan(pos, e), readErr, toks
 	} else if b.tbx.VReader != nil {
-		start := int(b.region.Start())
-		alt := strings.Split(string(toks[4]), ",")
-		lref := len(toks[3])
-		if start >= pos+lref {
-			for _, a := range alt {
-				if a[0] != '<' || a == "<CN0>" {
-					e := pos + lref
-					if e > start {
-						return true, readErr, toks
-					}
-				} else if strings.HasPrefix(a, "<DEL") || strings.HasPrefix(a, "<DUP") || strings.HasPrefix(a, "<INV") || strings.HasPrefix(a, "<CN") {
-					info := string(toks[7])
-					var idx int
-					if idx = strings.Index(info, ";END="); idx == -1 {
-						if strings.HasPrefix(info, "END=") {
-							idx = 0
-						}
-					}
-					if idx != -1 {
-						v := info[idx+5 : idx+5+strings.Index(info[idx+5:], ";")]
-						e, err := strconv.Atoi(v)
-						if err != nil {
-							return false, err, toks
-						}
-						if e > start {
-							return true, readErr, toks
-						}
-						return false, readErr, toks
-					} else {
-						log.Println("no end:", b.tbx.path, string(toks[0]), pos, string(toks[3]), a)
-					}
-				}
+		in, err := b.vcfOverlaps(toks, pos, lo)
+		return in, err, toks
+	}
+	// a generic file without an end column has single-base records.
+	return pos+1 > lo && b.tbx.inSpan(pos, pos+1), readErr, toks
+}
+
+// vcfOverlaps returns true if the VCF record with fields toks, which may be
+// only the first 8, and 0-based start pos ends after lo.
+func (b *bixerator) vcfOverlaps(toks [][]byte, pos, lo int) (bool, error) {
+	ref, alt, info := toks[vcfRef], toks[vcfAlt], toks[vcfInfo]
+	if c := b.tbx.vcfCols; c != nil {
+		ref, alt, info = toks[c[vcfRef]], toks[c[vcfAlt]], toks[c[vcfInfo]]
+	}
+	end := pos + len(ref)
+	// the INFO END is only needed if the ref does not reach the region or
+	// the span of the record is limited.
+	if (end > lo && b.tbx.maxSpan == 0) || !symbolicAlt(alt) {
+		return end > lo && b.tbx.inSpan(pos, end), nil
+	}
+	// a symbolic allele may span past its ref to the INFO END.
+	e, ok, err := infoEnd(info)
+	if err != nil {
+		return false, err
+	}
+	if ok && e > end {
+		end = e
+	} else if !ok {
+		for _, a := range strings.Split(string(alt), ",") {
+			if strings.HasPrefix(a, "<DEL") || strings.HasPrefix(a, "<DUP") || strings.HasPrefix(a, "<INV") || strings.HasPrefix(a, "<CN") {
+				log.Println("no end:", b.tbx.path, string(toks[0]), pos, string(ref), a)
+				break
 			}
-		} else {
-			return true, readErr, toks
 		}
-		return false, readErr, toks
 	}
-	return false, readErr, toks
+	return end > lo && b.tbx.inSpan(pos, end), nil
+}
+
+// quickVCF returns true if the bounds of a line can be checked with
+// vcfInBounds, as for a VCF with the usual columns.
+func (tbx *Bix) quickVCF() bool {
+	return tbx.VReader != nil && tbx.lengthColumn == 0 && tbx.EndColumn() == 0 &&
+		tbx.BeginColumn() == 2 && tbx.delim == '\t'
+}
+
+// vcfInBounds is inBounds for a VCF. Only the first 8 fields, which hold the
+// position, ref, alt and INFO, are found to check the bounds, and the line is
+// split into all of its fields only if it is in the region, so lines outside
+// of it, including the sample columns of a wide VCF, are not split. The
+// fields are nil if the line is not in the region.
+func (b *bixerator) vcfInBounds(line []byte) (bool, error, [][]byte) {
+	head := b.head[:0]
+	rest := line
+	for len(head) < len(b.head)-1 {
+		i := bytes.IndexByte(rest, '\t')
+		if i == -1 {
+			break
+		}
+		head = append(head, rest[:i])
+		rest = rest[i+1:]
+	}
+	if i := bytes.IndexByte(rest, '\t'); i != -1 {
+		rest = rest[:i]
+	}
+	head = append(head, rest)
+	if len(head) < len(b.head) {
+		// a short line gives the same errors as for other files.
+		return b.inBounds(line)
+	}
+
+	pos, err := parsePos(head[1])
+	if err != nil {
+		return false, err, nil
+	}
+	// VCF is 1-based.
+	pos--
+	if pos >= b.tbx.regionEnd(b.region) {
+		return false, io.EOF, nil
+	}
+	in, err := b.vcfOverlaps(head, pos, b.tbx.regionStart(b.region))
+	if err != nil || !in {
+		return false, err, nil
+	}
+	return true, nil, b.split(line)
+}
 
+// parsePos parses a position, which is usually short and unsigned, without
+// the generality of strconv.Atoi, which is used for anything else so that
+// the errors are the same.
+func parsePos(f []byte) (int, error) {
+	if len(f) == 0 || len(f) > 18 {
+		return strconv.Atoi(string(f))
+	}
+	n := 0
+	for _, c := range f {
+		if c < '0' || c > '9' {
+			return strconv.Atoi(string(f))
+		}
+		n = n*10 + int(c-'0')
+	}
+	return n, nil
 }
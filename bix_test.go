@@ -0,0 +1,2435 @@
+package bix
+
+import (
+	"bufio"
+	"bytes"
+	"compress/gzip"
+	"fmt"
+	"hash/crc32"
+	"io"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+
+	"github.com/biogo/hts/bgzf"
+	"github.com/biogo/hts/tabix"
+	"github.com/brentp/irelate/interfaces"
+	"github.com/brentp/irelate/parsers"
+	"github.com/brentp/vcfgo"
+	"github.com/pkg/errors"
+	. "gopkg.in/check.v1"
+)
+
+type countWriter struct {
+	w *os.File
+	n int64
+}
+
+func (c *countWriter) Write(b []byte) (int, error) {
+	n, err := c.w.Write(b)
+	c.n += int64(n)
+	return n, err
+}
+
+type tbxRecord struct {
+	chrom      string
+	start, end int
+}
+
+func (r tbxRecord) RefName() string { return r.chrom }
+func (r tbxRecord) Start() int      { return r.start }
+func (r tbxRecord) End() int        { return r.end }
+
+// writeTabix writes lines to a bgzf file called name in a temporary
+// directory and indexes it with the settings in idx. Each data line is
+// written to its own block. It returns the path to the data file.
+func writeTabix(c *C, name string, idx *tabix.Index, lines []string) string {
+	return writeTabixBlocks(c, name, idx, lines, 1)
+}
+
+// writeTabixBlocks is like writeTabix but writes up to perBlock data lines to
+// each block, all of which are indexed with the chunk for the whole block.
+func writeTabixBlocks(c *C, name string, idx *tabix.Index, lines []string, perBlock int) string {
+	path := filepath.Join(c.MkDir(), name)
+	f, err := os.Create(path)
+	c.Assert(err, IsNil)
+	cw := &countWriter{w: f}
+	w := bgzf.NewWriter(cw, 1)
+
+	var block []tbxRecord
+	begin := cw.n
+	flush := func() {
+		c.Assert(w.Flush(), IsNil)
+		c.Assert(w.Wait(), IsNil)
+		chunk := bgzf.Chunk{Begin: bgzf.Offset{File: begin}, End: bgzf.Offset{File: cw.n}}
+		for _, r := range block {
+			c.Assert(idx.Add(r, chunk, true, true), IsNil)
+			// Add does not record new reference names in the ID map.
+			if _, ok := idx.IDs()[r.chrom]; !ok {
+				idx.IDs()[r.chrom] = len(idx.Names()) - 1
+			}
+		}
+		block, begin = block[:0], cw.n
+	}
+	for i, l := range lines {
+		_, err := w.Write([]byte(l + "\n"))
+		c.Assert(err, IsNil)
+		if i < int(idx.Skip) || strings.TrimSpace(l) == "" || rune(l[0]) == idx.MetaChar ||
+			strings.HasPrefix(l, "track ") || strings.HasPrefix(l, "browser ") {
+			flush()
+			continue
+		}
+		toks := strings.FieldsFunc(strings.TrimRight(l, "\r"), func(r rune) bool { return r == '\t' || r == ' ' })
+		s, err := strconv.Atoi(toks[idx.BeginColumn-1])
+		c.Assert(err, IsNil)
+		if !idx.ZeroBased {
+			s--
+		}
+		e := s + 1
+		if idx.EndColumn != 0 && idx.EndColumn != idx.BeginColumn {
+			e, err = strconv.Atoi(toks[idx.EndColumn-1])
+			c.Assert(err, IsNil)
+		}
+		block = append(block, tbxRecord{toks[idx.NameColumn-1], s, e})
+		if len(block) == perBlock {
+			flush()
+		}
+	}
+	if len(block) > 0 {
+		flush()
+	}
+	c.Assert(w.Close(), IsNil)
+	c.Assert(f.Close(), IsNil)
+
+	fi, err := os.Create(path + ".tbi")
+	c.Assert(err, IsNil)
+	wi := bgzf.NewWriter(fi, 1)
+	c.Assert(tabix.WriteTo(wi, idx), IsNil)
+	c.Assert(wi.Close(), IsNil)
+	c.Assert(fi.Close(), IsNil)
+	return path
+}
+
+// bedIndex returns an empty tabix index with the settings used for BED.
+func bedIndex() *tabix.Index {
+	idx := tabix.New()
+	idx.NameColumn, idx.BeginColumn, idx.EndColumn = 1, 2, 3
+	idx.ZeroBased = true
+	idx.MetaChar = '#'
+	return idx
+}
+
+// collect returns the records from it, closing it when done.
+func collect(c *C, it interfaces.RelatableIterator) []interfaces.Relatable {
+	var recs []interfaces.Relatable
+	for {
+		v, err := it.Next()
+		if err == io.EOF {
+			break
+		}
+		c.Assert(err, IsNil)
+		recs = append(recs, v)
+	}
+	c.Assert(it.Close(), IsNil)
+	return recs
+}
+
+func (s *BixSuite) TestCopyRegion(c *C) {
+	tbx, err := New("tests/csitest.bed.gz")
+	c.Assert(err, IsNil)
+	defer tbx.Close()
+
+	var buf bytes.Buffer
+	n, err := tbx.CopyRegion(&buf, interfaces.AsIPosition("6", 12000, 12100), nil)
+	c.Assert(err, IsNil)
+	c.Check(n, Equals, 3)
+	c.Check(buf.String(), Equals, strings.Repeat("6\t11869\t12227\tENSE00002234944\t6.47\n", 3))
+
+	buf.Reset()
+	n, err = tbx.CopyRegion(&buf, interfaces.AsIPosition("6", 12000, 12100), func(r interfaces.Relatable) bool {
+		return r.Chrom() != "6"
+	})
+	c.Assert(err, IsNil)
+	c.Check(n, Equals, 0)
+	c.Check(buf.Len(), Equals, 0)
+
+	buf.Reset()
+	n, err = tbx.CopyRegion(&buf, interfaces.AsIPosition("1", 12000, 12100), func(r interfaces.Relatable) bool {
+		return string(r.(*parsers.Interval).Fields[3]) == "ENSE00002234944"
+	})
+	c.Assert(err, IsNil)
+	c.Check(n, Equals, 1)
+}
+
+func (s *BixSuite) TestNumRefs(c *C) {
+	tbx, err := New("tests/csitest.bed.gz")
+	c.Assert(err, IsNil)
+	defer tbx.Close()
+	var idx Index = tbx.Index
+	c.Check(idx.NumRefs(), Equals, 7)
+
+	vcf, err := New("main/NA12878.wham.del.vcf.gz")
+	c.Assert(err, IsNil)
+	defer vcf.Close()
+	idx = vcf.Index
+	c.Check(idx.NumRefs() > 0, Equals, true)
+}
+
+func (s *BixSuite) TestCanonicalContig(c *C) {
+	tbx, err := New("tests/csitest.bed.gz")
+	c.Assert(err, IsNil)
+	defer tbx.Close()
+
+	name, ok := tbx.CanonicalContig("chr6")
+	c.Check(ok, Equals, true)
+	c.Check(name, Equals, "6")
+
+	name, ok = tbx.CanonicalContig("9")
+	c.Check(ok, Equals, true)
+	c.Check(name, Equals, "9")
+
+	_, ok = tbx.CanonicalContig("chr7")
+	c.Check(ok, Equals, false)
+}
+
+func (s *BixSuite) TestOutputContigStyle(c *C) {
+	bed, err := New(writeTabix(c, "style.bed.gz", bedIndex(), []string{
+		"1\t10\t20",
+		"chrUn\t5\t8",
+	}))
+	c.Assert(err, IsNil)
+	defer bed.Close()
+	vcf, err := New("main/NA12878.wham.del.vcf.gz")
+	c.Assert(err, IsNil)
+	defer vcf.Close()
+
+	chroms := func(tbx *Bix, region interfaces.IPosition) []string {
+		it, err := tbx.Query(region)
+		c.Assert(err, IsNil)
+		defer it.Close()
+		var chroms []string
+		for {
+			// records may be reused, so each is checked before the next.
+			r, err := it.Next()
+			if err == io.EOF {
+				return chroms
+			}
+			c.Assert(err, IsNil)
+			if n := len(chroms); n == 0 || chroms[n-1] != r.Chrom() {
+				chroms = append(chroms, strings.Clone(r.Chrom()))
+			}
+		}
+	}
+	c.Check(chroms(bed, nil), DeepEquals, []string{"1", "chrUn"})
+
+	for _, reuse := range []bool{false, true} {
+		bed.SetReuseBuffers(reuse)
+		bed.SetOutputContigStyle(WithChr)
+		c.Check(chroms(bed, nil), DeepEquals, []string{"chr1", "chrUn"})
+		c.Check(chroms(bed, interfaces.AsIPosition("1", 0, 100)), DeepEquals, []string{"chr1"})
+		bed.SetOutputContigStyle(WithoutChr)
+		c.Check(chroms(bed, nil), DeepEquals, []string{"1", "Un"})
+	}
+
+	vcf.SetOutputContigStyle(WithChr)
+	c.Check(chroms(vcf, interfaces.AsIPosition("chr1", 755600, 755700)), DeepEquals, []string{"chr1"})
+	it, err := vcf.QueryRaw(interfaces.AsIPosition("1", 755600, 755700))
+	c.Assert(err, IsNil)
+	defer it.Close()
+	line, err := it.Next()
+	c.Assert(err, IsNil)
+	c.Check(strings.HasPrefix(string(line), "1\t"), Equals, true)
+}
+
+func (s *BixSuite) TestContigAliases(c *C) {
+	tbx, err := New(writeTabix(c, "acc.bed.gz", bedIndex(), []string{
+		"NC_000001.11\t10\t20",
+		"NC_000002.12\t30\t40",
+	}))
+	c.Assert(err, IsNil)
+	defer tbx.Close()
+
+	_, ok := tbx.CanonicalContig("chr1")
+	c.Check(ok, Equals, false)
+
+	tbx.SetContigAliases(map[string]string{"1": "NC_000001.11", "chr2": "NC_000002.12", "3": "NC_000003.12"})
+	for _, t := range []struct {
+		chrom, name string
+		ok          bool
+	}{
+		{"1", "NC_000001.11", true},
+		{"chr1", "NC_000001.11", true},
+		{"2", "NC_000002.12", true},
+		{"NC_000002.12", "NC_000002.12", true},
+		{"3", "", false},
+	} {
+		name, ok := tbx.CanonicalContig(t.chrom)
+		c.Check(ok, Equals, t.ok, Commentf(t.chrom))
+		c.Check(name, Equals, t.name, Commentf(t.chrom))
+	}
+
+	it, err := tbx.Query(interfaces.AsIPosition("chr1", 0, 100))
+	c.Assert(err, IsNil)
+	recs := collect(c, it)
+	c.Assert(recs, HasLen, 1)
+	c.Check(recs[0].Chrom(), Equals, "NC_000001.11")
+}
+
+func (s *BixSuite) TestWriteTabix(c *C) {
+	path := writeTabix(c, "t.bed.gz", bedIndex(), []string{
+		"#chrom\tstart\tend",
+		"chr1\t10\t20",
+		"chr1\t30\t40",
+		"chr2\t5\t6",
+	})
+	tbx, err := New(path)
+	c.Assert(err, IsNil)
+	defer tbx.Close()
+
+	it, err := tbx.Query(interfaces.AsIPosition("chr1", 15, 35))
+	c.Assert(err, IsNil)
+	c.Check(collect(c, it), HasLen, 2)
+
+	it, err = tbx.Query(interfaces.AsIPosition("chr2", 0, 100))
+	c.Assert(err, IsNil)
+	c.Check(collect(c, it), HasLen, 1)
+}
+
+func (s *BixSuite) TestLengthColumn(c *C) {
+	idx := bedIndex()
+	idx.EndColumn = 2
+	path := writeTabix(c, "len.bed.gz", idx, []string{
+		"chr1\t100\t50\ta",
+		"chr1\t300\t5\tb",
+	})
+	tbx, err := New(path)
+	c.Assert(err, IsNil)
+	defer tbx.Close()
+	tbx.SetLengthColumn(3)
+
+	it, err := tbx.Query(interfaces.AsIPosition("chr1", 120, 140))
+	c.Assert(err, IsNil)
+	recs := collect(c, it)
+	c.Assert(recs, HasLen, 1)
+	c.Check(recs[0].Start(), Equals, uint32(100))
+	c.Check(recs[0].End(), Equals, uint32(150))
+
+	it, err = tbx.Query(interfaces.AsIPosition("chr1", 150, 300))
+	c.Assert(err, IsNil)
+	c.Check(collect(c, it), HasLen, 0)
+}
+
+func (s *BixSuite) TestPeek(c *C) {
+	tbx, err := New("tests/csitest.bed.gz")
+	c.Assert(err, IsNil)
+	defer tbx.Close()
+
+	it, err := tbx.Query(interfaces.AsIPosition("6", 12000, 12100))
+	c.Assert(err, IsNil)
+	p := it.(Peeker)
+
+	a, err := p.Peek()
+	c.Assert(err, IsNil)
+	b, err := p.Peek()
+	c.Assert(err, IsNil)
+	c.Check(a, Equals, b)
+	n, err := p.Next()
+	c.Assert(err, IsNil)
+	c.Check(n, Equals, a)
+
+	c.Check(collect(c, p), HasLen, 2)
+}
+
+func (s *BixSuite) TestQueryPastEnd(c *C) {
+	path := writeTabix(c, "end.bed.gz", bedIndex(), []string{
+		"chr1\t10\t20",
+		"chr1\t30\t40",
+		"chr2\t5\t6",
+	})
+	tbx, err := New(path)
+	c.Assert(err, IsNil)
+	defer tbx.Close()
+
+	for _, r := range []struct {
+		start, end int
+		n          int
+	}{
+		{30, 40, 1},
+		{39, 40, 1},
+		{41, 42, 0},
+		{41, 100, 0},
+		{1000, 2000, 0},
+		{20000, 30000, 0},
+		{1 << 28, 1<<28 + 10, 0},
+	} {
+		for _, q := range []func(interfaces.IPosition) (interfaces.RelatableIterator, error){tbx.Query, tbx.FastQuery} {
+			it, err := q(interfaces.AsIPosition("chr1", r.start, r.end))
+			c.Assert(err, IsNil)
+			c.Check(collect(c, it), HasLen, r.n, Commentf("%d-%d", r.start, r.end))
+			_, err = it.Next()
+			c.Check(err, Equals, io.EOF)
+		}
+	}
+}
+
+func (s *BixSuite) TestNoTrailingNewline(c *C) {
+	tbx, err := New(writeTabix(c, "nl.bed.gz", bedIndex(), []string{"6\t10\t20"}))
+	c.Assert(err, IsNil)
+	defer tbx.Close()
+	b := &bixerator{buf: bufio.NewReader(strings.NewReader("6\t10\t20\n6\t30\t40")), tbx: tbx}
+	v, err := b.Next()
+	c.Assert(err, IsNil)
+	c.Check(v.Start(), Equals, uint32(10))
+	v, err = b.Next()
+	c.Assert(err, IsNil)
+	c.Check(v.Start(), Equals, uint32(30))
+	_, err = b.Next()
+	c.Check(err, Equals, io.EOF)
+}
+
+func (s *BixSuite) TestFormatVCF(c *C) {
+	idx := tabix.New()
+	idx.Format = FormatVCF
+	idx.NameColumn, idx.BeginColumn, idx.EndColumn = 1, 2, 0
+	idx.MetaChar = '#'
+	path := writeTabix(c, "variants.txt.gz", idx, []string{
+		"##fileformat=VCFv4.1",
+		"#CHROM\tPOS\tID\tREF\tALT\tQUAL\tFILTER\tINFO",
+		"1\t100\t.\tA\tG\t50\tPASS\tDP=10",
+	})
+	tbx, err := New(path)
+	c.Assert(err, IsNil)
+	defer tbx.Close()
+	c.Check(tbx.Format(), Equals, FormatVCF)
+	c.Assert(tbx.VReader, NotNil)
+
+	it, err := tbx.Query(interfaces.AsIPosition("1", 99, 100))
+	c.Assert(err, IsNil)
+	recs := collect(c, it)
+	c.Assert(recs, HasLen, 1)
+	c.Check(recs[0].(interfaces.IVariant).Ref(), Equals, "A")
+}
+
+func (s *BixSuite) TestVCFHeaderDetection(c *C) {
+	idx := tabix.New()
+	idx.NameColumn, idx.BeginColumn, idx.EndColumn = 1, 2, 2
+	idx.MetaChar = '#'
+	path := writeTabix(c, "tmp1234.gz", idx, []string{
+		"##fileformat=VCFv4.2",
+		"##INFO=<ID=DP,Number=1,Type=Integer,Description=\"Depth\">",
+		"#CHROM\tPOS\tID\tREF\tALT\tQUAL\tFILTER\tINFO",
+		"1\t100\t.\tA\tG\t50\tPASS\tDP=10",
+	})
+	tbx, err := New(path)
+	c.Assert(err, IsNil)
+	defer tbx.Close()
+	c.Assert(tbx.VReader, NotNil)
+	c.Check(tbx.GetHeaderType("DP"), Equals, "Integer")
+
+	c.Check(isVCFHeader([]string{"#chrom\tstart\tend\n"}), Equals, false)
+	c.Check(isVCFHeader([]string{"##fileformat=VCFv4.2\n"}), Equals, false)
+}
+
+func (s *BixSuite) TestColumnNames(c *C) {
+	tbx, err := New(writeTabix(c, "genes.bed.gz", bedIndex(), []string{
+		"#chrom\tstart\tend\tgene\tscore",
+		"chr1\t10\t20\tA\t1",
+	}))
+	c.Assert(err, IsNil)
+	defer tbx.Close()
+	c.Check(tbx.ColumnNames(), DeepEquals, []string{"chrom", "start", "end", "gene", "score"})
+	tbx.ColumnNames()[0] = "x"
+	c.Check(tbx.ColumnNames()[0], Equals, "chrom")
+
+	bare, err := New(writeTabix(c, "bare.bed.gz", bedIndex(), []string{"chr1\t10\t20"}))
+	c.Assert(err, IsNil)
+	defer bare.Close()
+	c.Check(bare.ColumnNames(), IsNil)
+
+	vcf, err := New("main/NA12878.wham.del.vcf.gz")
+	c.Assert(err, IsNil)
+	defer vcf.Close()
+	c.Check(vcf.ColumnNames(), IsNil)
+}
+
+func (s *BixSuite) TestHeaderLine(c *C) {
+	tbx, err := New(writeTabix(c, "hl.bed.gz", bedIndex(), []string{
+		"#track name=variants",
+		"#chrom\tstart\tend\tref\talt",
+		"#generated by hand",
+		"chr1\t10\t11\tA\tT",
+	}))
+	c.Assert(err, IsNil)
+	defer tbx.Close()
+	c.Check(tbx.ColumnNames(), DeepEquals, []string{"chrom", "start", "end", "ref", "alt"})
+	it, err := tbx.Query(interfaces.AsIPosition("chr1", 0, 100))
+	c.Assert(err, IsNil)
+	recs := collect(c, it)
+	c.Assert(recs, HasLen, 1)
+	c.Check(recs[0].(interfaces.IRefAlt).Alt(), DeepEquals, []string{"T"})
+
+	c.Assert(tbx.SetHeaderLine(3), IsNil)
+	c.Check(tbx.ColumnNames(), DeepEquals, []string{"generated by hand"})
+	it, err = tbx.Query(interfaces.AsIPosition("chr1", 0, 100))
+	c.Assert(err, IsNil)
+	recs = collect(c, it)
+	c.Assert(recs, HasLen, 1)
+	_, ok := recs[0].(interfaces.IRefAlt)
+	c.Check(ok, Equals, false)
+
+	c.Assert(tbx.SetHeaderLine(2), IsNil)
+	c.Check(tbx.ColumnNames(), DeepEquals, []string{"chrom", "start", "end", "ref", "alt"})
+	c.Check(tbx.SetHeaderLine(0), NotNil)
+	c.Check(tbx.SetHeaderLine(4), NotNil)
+
+	// blank lines are not counted.
+	blank, err := New(writeTabix(c, "hlblank.bed.gz", bedIndex(), []string{
+		"#track name=variants",
+		"",
+		"#chrom\tstart\tend\tname",
+		"#comment",
+		"chr1\t10\t11\tA",
+	}))
+	c.Assert(err, IsNil)
+	defer blank.Close()
+	c.Assert(blank.SetHeaderLine(2), IsNil)
+	c.Check(blank.ColumnNames(), DeepEquals, []string{"chrom", "start", "end", "name"})
+	c.Check(blank.SetHeaderLine(4), NotNil)
+
+	vcf, err := New("main/NA12878.wham.del.vcf.gz")
+	c.Assert(err, IsNil)
+	defer vcf.Close()
+	c.Check(vcf.SetHeaderLine(1), NotNil)
+}
+
+func (s *BixSuite) TestOverlaps(c *C) {
+	tbx, err := New(writeTabix(c, "o.bed.gz", bedIndex(), []string{
+		"chr1\t10\t20",
+		"chr1\t30\t40",
+	}))
+	c.Assert(err, IsNil)
+	defer tbx.Close()
+
+	for _, r := range []struct {
+		chrom      string
+		start, end int
+		ok         bool
+	}{
+		{"chr1", 0, 5, false},
+		{"chr1", 15, 16, true},
+		{"chr1", 22, 28, false},
+		{"chr1", 0, 100, true},
+		{"chr1", 50, 100, false},
+		{"chr2", 10, 20, false},
+	} {
+		ok, err := tbx.Overlaps(interfaces.AsIPosition(r.chrom, r.start, r.end))
+		c.Assert(err, IsNil)
+		c.Check(ok, Equals, r.ok, Commentf("%s:%d-%d", r.chrom, r.start, r.end))
+	}
+}
+
+func (s *BixSuite) TestDelimiter(c *C) {
+	tbx, err := New(writeTabix(c, "space.bed.gz", bedIndex(), []string{
+		"chr1 10 20 a",
+		"chr1 30 40 b",
+	}))
+	c.Assert(err, IsNil)
+	defer tbx.Close()
+	tbx.SetDelimiter(' ')
+
+	it, err := tbx.Query(interfaces.AsIPosition("chr1", 15, 25))
+	c.Assert(err, IsNil)
+	recs := collect(c, it)
+	c.Assert(recs, HasLen, 1)
+	c.Check(recs[0].End(), Equals, uint32(20))
+	c.Check(string(recs[0].(*parsers.Interval).Fields[3]), Equals, "a")
+
+	it, err = tbx.Query(nil)
+	c.Assert(err, IsNil)
+	c.Check(collect(c, it), HasLen, 2)
+}
+
+func (s *BixSuite) TestQuoted(c *C) {
+	tbx, err := New(writeTabix(c, "quoted.bed.gz", bedIndex(), []string{
+		"chr1\t10\t20\t\"kinase\tdomain\"\t0\t+",
+		"chr1\t30\t40\t\"plain\"\t0\t-",
+		"chr1\t50\t60\tunquoted\t0\t+",
+	}))
+	c.Assert(err, IsNil)
+	defer tbx.Close()
+
+	descriptions := func() []string {
+		it, err := tbx.Query(interfaces.AsIPosition("chr1", 0, 100))
+		c.Assert(err, IsNil)
+		defer it.Close()
+		var descs []string
+		for {
+			r, err := it.Next()
+			if err == io.EOF {
+				return descs
+			}
+			c.Assert(err, IsNil)
+			// the fields may be reused by the next record.
+			descs = append(descs, string(recordFields(r)[3]))
+		}
+	}
+	c.Assert(tbx.SetStrand('+'), IsNil)
+	// the tab in the first description moves the strand.
+	c.Check(descriptions(), DeepEquals, []string{"unquoted"})
+
+	tbx.SetQuoted(true)
+	c.Check(descriptions(), DeepEquals, []string{"\"kinase\tdomain\"", "unquoted"})
+	tbx.SetReuseBuffers(true)
+	c.Check(descriptions(), DeepEquals, []string{"\"kinase\tdomain\"", "unquoted"})
+
+	c.Check(splitQuoted(nil, []byte(`a,"b,c",,"d""e,f"`), ','), DeepEquals,
+		[][]byte{[]byte("a"), []byte(`"b,c"`), []byte(""), []byte(`"d""e,f"`)})
+}
+
+func (s *BixSuite) TestRecordKey(c *C) {
+	tbx, err := New(writeTabix(c, "key.bed.gz", bedIndex(), []string{
+		"chr1\t10\t20\ta",
+		"chr1\t10\t20\ta",
+		"chr1\t10\t20\tb",
+		"chr1\t10\t30\ta",
+	}))
+	c.Assert(err, IsNil)
+	defer tbx.Close()
+	it, err := tbx.Query(nil)
+	c.Assert(err, IsNil)
+	var keys []string
+	for _, r := range collect(c, it) {
+		keys = append(keys, RecordKey(r))
+	}
+	c.Check(keys[0], Matches, "chr1:10:20:[0-9a-f]{16}")
+	c.Check(keys[1], Equals, keys[0])
+	c.Check(keys[2], Not(Equals), keys[0])
+	c.Check(keys[3], Matches, "chr1:10:30:[0-9a-f]{16}")
+
+	// the key of a clipped record is that of the record.
+	it, err = tbx.QueryClipped(interfaces.AsIPosition("chr1", 15, 16))
+	c.Assert(err, IsNil)
+	c.Check(RecordKey(collect(c, it)[0]), Equals, keys[0])
+
+	vcf, err := New("main/NA12878.wham.del.vcf.gz")
+	c.Assert(err, IsNil)
+	defer vcf.Close()
+	it, err = vcf.Query(interfaces.AsIPosition("1", 755600, 755700))
+	c.Assert(err, IsNil)
+	recs := collect(c, it)
+	c.Assert(recs, Not(HasLen), 0)
+	v := recs[0].(interfaces.IVariant)
+	c.Check(RecordKey(recs[0]), Equals, fmt.Sprintf("1:%d:%s:%s", v.Start()+1, v.Ref(), strings.Join(v.Alt(), ",")))
+
+	c.Check(RecordKey(parsers.NewInterval("chr2", 5, 8, nil, 0, nil)), Equals, "chr2:5:8")
+}
+
+func (s *BixSuite) TestVCFColumns(c *C) {
+	idx := func() *tabix.Index {
+		idx := tabix.New()
+		idx.NameColumn, idx.BeginColumn, idx.EndColumn = 1, 2, 0
+		idx.MetaChar = '#'
+		return idx
+	}
+	for _, t := range []struct {
+		header, rec1, rec2 string
+	}{
+		{"#CHROM\tPOS\tID\tREF\tALT\tQUAL\tFILTER\tINFO",
+			"1\t100\t.\tACGT\tA\t50\tPASS\tDP=3",
+			"1\t200\t.\tC\t<DEL>\t50\tPASS\tEND=300"},
+		// REF and ALT are swapped and INFO is before QUAL.
+		{"#CHROM\tPOS\tID\tALT\tREF\tINFO\tQUAL\tFILTER",
+			"1\t100\t.\tA\tACGT\tDP=3\t50\tPASS",
+			"1\t200\t.\t<DEL>\tC\tEND=300\t50\tPASS"},
+	} {
+		tbx, err := New(writeTabix(c, "cols.vcf.gz", idx(), []string{"##fileformat=VCFv4.2", t.header, t.rec1, t.rec2}))
+		c.Assert(err, IsNil)
+		it, err := tbx.Query(nil)
+		c.Assert(err, IsNil)
+		recs := collect(c, it)
+		c.Assert(recs, HasLen, 2)
+		v := recs[0].(interfaces.IVariant)
+		c.Check(v.Ref(), Equals, "ACGT", Commentf(t.header))
+		c.Check(v.Alt(), DeepEquals, []string{"A"}, Commentf(t.header))
+		c.Check(recs[0].End(), Equals, uint32(103), Commentf(t.header))
+		c.Check(recs[1].End(), Equals, uint32(300), Commentf(t.header))
+
+		// the ref of the first and the END of the second reach the region.
+		for _, q := range []struct {
+			start, end int
+			n          int
+		}{{102, 103, 1}, {250, 260, 1}, {103, 104, 0}} {
+			it, err := tbx.Query(interfaces.AsIPosition("1", q.start, q.end))
+			c.Assert(err, IsNil)
+			c.Check(collect(c, it), HasLen, q.n, Commentf("%s %d-%d", t.header, q.start, q.end))
+		}
+		c.Check(tbx.Close(), IsNil)
+	}
+
+	_, err := New(writeTabix(c, "bad.vcf.gz", idx(), []string{
+		"##fileformat=VCFv4.2",
+		"#CHROM\tPOS\tID\tREF\tALT\tQUAL\tFILTER",
+		"1\t100\t.\tA\tC\t50\tPASS",
+	}))
+	c.Check(err, ErrorMatches, ".*VCF column INFO not found .*")
+}
+
+func (s *BixSuite) TestMaxSpan(c *C) {
+	tbx, err := New(writeTabix(c, "span.bed.gz", bedIndex(), []string{
+		"chr1\t0\t5000\tcnv",
+		"chr1\t100\t101\tsnp",
+		"chr1\t200\t210\tindel",
+	}))
+	c.Assert(err, IsNil)
+	defer tbx.Close()
+
+	names := func(q interface {
+		Query(interfaces.IPosition) (interfaces.RelatableIterator, error)
+	}) []string {
+		it, err := q.Query(interfaces.AsIPosition("chr1", 50, 300))
+		c.Assert(err, IsNil)
+		var names []string
+		for _, r := range collect(c, it) {
+			names = append(names, string(recordFields(r)[3]))
+		}
+		return names
+	}
+	c.Check(names(tbx), DeepEquals, []string{"cnv", "snp", "indel"})
+	tbx.SetMaxSpan(10)
+	c.Check(names(tbx), DeepEquals, []string{"snp", "indel"})
+	m, err := tbx.LoadMemory()
+	c.Assert(err, IsNil)
+	c.Check(names(m), DeepEquals, []string{"snp", "indel"})
+	tbx.SetMaxSpan(9)
+	c.Check(names(tbx), DeepEquals, []string{"snp"})
+
+	idx := tabix.New()
+	idx.NameColumn, idx.BeginColumn, idx.EndColumn = 1, 2, 0
+	idx.MetaChar = '#'
+	vcf, err := New(writeTabix(c, "span.vcf.gz", idx, []string{
+		"##fileformat=VCFv4.2",
+		"#CHROM\tPOS\tID\tREF\tALT\tQUAL\tFILTER\tINFO",
+		"1\t100\t.\tA\t<DEL>\t50\tPASS\tEND=2000000",
+		"1\t150\t.\tACGT\tA\t50\tPASS\t.",
+		"1\t160\t.\tC\t<DEL>\t50\tPASS\tEND=170",
+	}))
+	c.Assert(err, IsNil)
+	defer vcf.Close()
+	vcf.SetMaxSpan(100)
+	it, err := vcf.Query(interfaces.AsIPosition("1", 120, 200))
+	c.Assert(err, IsNil)
+	var starts []uint32
+	for _, r := range collect(c, it) {
+		starts = append(starts, r.Start())
+	}
+	c.Check(starts, DeepEquals, []uint32{149, 159})
+}
+
+func (s *BixSuite) TestContigLengths(c *C) {
+	tbx, err := New(writeTabix(c, "lengths.bed.gz", bedIndex(), []string{
+		"chr1\t10\t20",
+		"chr1\t900\t1200",
+		"chr2\t10\t5000",
+	}))
+	c.Assert(err, IsNil)
+	defer tbx.Close()
+
+	ends := func(tbx *Bix) []uint32 {
+		it, err := tbx.Query(nil)
+		c.Assert(err, IsNil)
+		defer it.Close()
+		var ends []uint32
+		for {
+			v, err := it.Next()
+			if err == io.EOF {
+				return ends
+			}
+			c.Assert(err, IsNil)
+			ends = append(ends, v.End())
+		}
+	}
+	c.Check(ends(tbx), DeepEquals, []uint32{20, 1200, 5000})
+	// chr2 is not clamped as it has no length.
+	tbx.SetContigLengths(map[string]int{"1": 1000})
+	c.Check(ends(tbx), DeepEquals, []uint32{20, 1000, 5000})
+	tbx.SetReuseBuffers(true)
+	c.Check(ends(tbx), DeepEquals, []uint32{20, 1000, 5000})
+	tbx.SetContigLengths(nil)
+	c.Check(ends(tbx), DeepEquals, []uint32{20, 1200, 5000})
+
+	idx := tabix.New()
+	idx.NameColumn, idx.BeginColumn, idx.EndColumn = 1, 2, 0
+	idx.MetaChar = '#'
+	vcf, err := New(writeTabix(c, "lengths.vcf.gz", idx, []string{
+		"##fileformat=VCFv4.2",
+		"#CHROM\tPOS\tID\tREF\tALT\tQUAL\tFILTER\tINFO",
+		"chr1\t100\t.\tA\t<DEL>\t50\tPASS\tEND=2000000",
+		"chr1\t150\t.\tACGT\tA\t50\tPASS\t.",
+		"chr1\t998\t.\tACGT\tA\t50\tPASS\t.",
+	}))
+	c.Assert(err, IsNil)
+	defer vcf.Close()
+	vcf.SetContigLengths(map[string]int{"chr1": 1000})
+	c.Check(ends(vcf), DeepEquals, []uint32{1000, 153, 1000})
+	it, err := vcf.QueryVariants(nil)
+	c.Assert(err, IsNil)
+	v, err := it.Next()
+	c.Assert(err, IsNil)
+	c.Check(v.Pos, Equals, uint64(100))
+	it.Close()
+
+	// a record that starts past the end of its contig ends at its start.
+	past, err := New(writeTabix(c, "past.bed.gz", bedIndex(), []string{
+		"chr1\t900\t1200",
+		"chr1\t1100\t1200",
+	}))
+	c.Assert(err, IsNil)
+	defer past.Close()
+	past.SetContigLengths(map[string]int{"chr1": 1000})
+	var warned []string
+	past.SetClampWarning(func(chrom string, start, end, length int) {
+		warned = append(warned, fmt.Sprintf("%s:%d-%d>%d", chrom, start, end, length))
+	})
+	for _, reuse := range []bool{false, true} {
+		warned = warned[:0]
+		past.SetReuseBuffers(reuse)
+		it, err := past.Query(nil)
+		c.Assert(err, IsNil)
+		var spans []string
+		for {
+			v, err := it.Next()
+			if err == io.EOF {
+				break
+			}
+			c.Assert(err, IsNil)
+			spans = append(spans, fmt.Sprintf("%d-%d", v.Start(), v.End()))
+		}
+		c.Assert(it.Close(), IsNil)
+		c.Check(spans, DeepEquals, []string{"900-1000", "1100-1100"})
+		c.Check(warned, DeepEquals, []string{"chr1:900-1200>1000", "chr1:1100-1200>1000"})
+	}
+}
+
+func (s *BixSuite) TestInvalidRegion(c *C) {
+	tbx, err := New("tests/csitest.bed.gz")
+	c.Assert(err, IsNil)
+	defer tbx.Close()
+
+	_, err = tbx.Query(interfaces.AsIPosition("1", 200, 100))
+	c.Check(err, ErrorMatches, ".*start is greater than end")
+	_, err = tbx.FastQuery(interfaces.AsIPosition("1", 200, 100))
+	c.Check(err, ErrorMatches, ".*start is greater than end")
+
+	_, err = tbx.Query(interfaces.AsIPosition("1", -10, -1))
+	c.Check(err, ErrorMatches, ".*start is likely negative")
+}
+
+// endIndex overrides the end column of an index.
+type endIndex struct {
+	Index
+	end int
+}
+
+func (e endIndex) EndColumn() int { return e.end }
+
+func (s *BixSuite) TestEndBeforeBegin(c *C) {
+	path := writeTabix(c, "cols.bed.gz", bedIndex(), []string{"chr1\t10\t20"})
+	idx, err := readIndexFile(path + ".tbi")
+	c.Assert(err, IsNil)
+
+	_, err = newWithIndex(path, path+".tbi", endIndex{idx, 1}, 1, nil)
+	c.Check(err, ErrorMatches, "bix: index .* has end column 1 before begin column 2")
+
+	for _, end := range []int{0, 3, 5} {
+		tbx, err := newWithIndex(path, path+".tbi", endIndex{idx, end}, 1, nil)
+		c.Assert(err, IsNil, Commentf("end column %d", end))
+		tbx.Close()
+	}
+}
+
+func (s *BixSuite) TestNewWithIndex(c *C) {
+	path := writeTabix(c, "w.bed.gz", bedIndex(), []string{
+		"chr1\t10\t20",
+		"chr1\t30\t40",
+	})
+	dir := c.MkDir()
+	idx := filepath.Join(dir, "some.index")
+	c.Assert(os.Rename(path+".tbi", idx), IsNil)
+
+	_, err := New(path)
+	c.Assert(err, NotNil)
+
+	tbx, err := NewWithIndex(path, idx, 1)
+	c.Assert(err, IsNil)
+	defer tbx.Close()
+	it, err := tbx.Query(interfaces.AsIPosition("chr1", 0, 100))
+	c.Assert(err, IsNil)
+	c.Check(collect(c, it), HasLen, 2)
+
+	csi, err := NewWithIndex("tests/csitest.bed.gz", "tests/csitest.bed.gz.csi", 1)
+	c.Assert(err, IsNil)
+	defer csi.Close()
+	c.Check(csi.NumRefs(), Equals, 7)
+
+	_, err = NewWithIndex(path, "tests/csitest.bed.gz", 1)
+	c.Check(err, ErrorMatches, ".*unknown index magic.*")
+}
+
+func (s *BixSuite) TestNewFromIndex(c *C) {
+	path := writeTabix(c, "f.bed.gz", bedIndex(), []string{
+		"chr1\t10\t20",
+		"chr1\t30\t40",
+	})
+	compressed, err := os.ReadFile(path + ".tbi")
+	c.Assert(err, IsNil)
+	gz, err := gzip.NewReader(bytes.NewReader(compressed))
+	c.Assert(err, IsNil)
+	raw, err := io.ReadAll(gz)
+	c.Assert(err, IsNil)
+
+	// the index may be given decompressed or as it is on disk.
+	for _, b := range [][]byte{raw, compressed} {
+		idx, err := ReadIndex(bytes.NewReader(b))
+		c.Assert(err, IsNil)
+		tbx, err := NewFromIndex(idx, path, 1)
+		c.Assert(err, IsNil)
+		it, err := tbx.Query(interfaces.AsIPosition("chr1", 0, 100))
+		c.Assert(err, IsNil)
+		c.Check(collect(c, it), HasLen, 2)
+		_, err = tbx.Fingerprint()
+		c.Check(err, ErrorMatches, "bix: fingerprint not supported .*")
+		c.Check(tbx.Close(), IsNil)
+	}
+
+	_, err = ReadIndex(bytes.NewReader(raw[:2]))
+	c.Check(err, NotNil)
+	_, err = NewFromIndex(nil, path, 1)
+	c.Check(err, ErrorMatches, "bix: nil index .*")
+}
+
+// copyFile copies src to dst.
+func copyFile(c *C, src, dst string) {
+	b, err := os.ReadFile(src)
+	c.Assert(err, IsNil)
+	c.Assert(os.WriteFile(dst, b, 0644), IsNil)
+}
+
+func (s *BixSuite) TestReopenError(c *C) {
+	path := filepath.Join(c.MkDir(), "gone.bed.gz")
+	src := writeTabix(c, "gone.bed.gz", bedIndex(), scanLines(3))
+	copyFile(c, src, path)
+	copyFile(c, src+".tbi", path+".tbi")
+	tbx, err := New(path)
+	c.Assert(err, IsNil)
+	// the file is reopened by the next query.
+	c.Assert(tbx.SetWorkers(2), IsNil)
+	c.Assert(os.Remove(path), IsNil)
+	// a file that cannot be reopened is not left half open.
+	_, err = tbx.FastQuery(interfaces.AsIPosition("chr1", 0, 10))
+	c.Check(err, ErrorMatches, "bix: error \\(re\\)opening .*")
+	c.Check(tbx.Close(), IsNil)
+}
+
+func (s *BixSuite) TestMisnamedIndex(c *C) {
+	path := filepath.Join(c.MkDir(), "csitest.bed.gz")
+	copyFile(c, "tests/csitest.bed.gz", path)
+	copyFile(c, "tests/csitest.bed.gz.csi", path+".tbi")
+
+	tbx, err := New(path)
+	c.Assert(err, IsNil)
+	defer tbx.Close()
+	_, ok := tbx.Index.(cIndex)
+	c.Check(ok, Equals, true)
+	it, err := tbx.Query(interfaces.AsIPosition("6", 12000, 12100))
+	c.Assert(err, IsNil)
+	c.Check(collect(c, it), HasLen, 3)
+
+	copyFile(c, "tests/csitest.bed.gz", path+".tbi")
+	_, err = New(path)
+	c.Check(err, ErrorMatches, ".*unknown index magic.*")
+}
+
+func (s *BixSuite) TestChunkInfo(c *C) {
+	tbx, err := New(writeTabix(c, "chunks.bed.gz", bedIndex(), scanLines(3000)))
+	c.Assert(err, IsNil)
+	defer tbx.Close()
+
+	n, small, err := tbx.ChunkInfo("chr1", 0, 16)
+	c.Assert(err, IsNil)
+	c.Check(n, Equals, 1)
+	c.Check(small > 0, Equals, true)
+
+	n, all, err := tbx.ChunkInfo("chr1", 0, maxEnd)
+	c.Assert(err, IsNil)
+	c.Check(n, Equals, 1)
+	c.Check(all > small, Equals, true)
+
+	// a chunk within a single block counts the whole block. This file has
+	// one block of data followed by the 28-byte EOF block.
+	vcf, err := New("main/NA12878.wham.del.vcf.gz")
+	c.Assert(err, IsNil)
+	defer vcf.Close()
+	offsets, err := vcf.BlockOffsets()
+	c.Assert(err, IsNil)
+	c.Assert(offsets, HasLen, 1)
+	fi, err := os.Stat("main/NA12878.wham.del.vcf.gz")
+	c.Assert(err, IsNil)
+	n, one, err := vcf.ChunkInfo("1", 755637, 755638)
+	c.Assert(err, IsNil)
+	c.Check(n, Equals, 1)
+	c.Check(one, Equals, fi.Size()-28)
+
+	n, size, err := tbx.ChunkInfo("chr2", 0, maxEnd)
+	c.Assert(err, IsNil)
+	c.Check(n, Equals, 0)
+	c.Check(size, Equals, int64(0))
+
+	tbx.SetStrict(true)
+	_, _, err = tbx.ChunkInfo("chr2", 0, maxEnd)
+	c.Check(errors.Cause(err), Equals, ErrUnknownContig)
+}
+
+func (s *BixSuite) TestMightOverlap(c *C) {
+	tbx, err := New(writeTabix(c, "sparse.bed.gz", bedIndex(), []string{
+		"chr1\t100\t200",
+		"chr1\t1000000\t1000100",
+		"chr2\t50000000\t50000100",
+	}))
+	c.Assert(err, IsNil)
+	defer tbx.Close()
+
+	for _, t := range []struct {
+		chrom      string
+		start, end int
+		might      bool
+	}{
+		{"chr1", 0, 1000, true},
+		// the index written by biogo has no linear index entry for the tile
+		// holding the end of the last record, so these start a tile earlier.
+		{"chr1", 990000, 1000060, true},
+		{"chr1", 500000, 500100, false},
+		{"chr1", 2000000, 3000000, false},
+		{"chr2", 0, 1000000, false},
+		{"chr2", 49970000, 50001000, true},
+		{"chr3", 0, 100, false},
+	} {
+		might, err := tbx.MightOverlap(t.chrom, t.start, t.end)
+		c.Assert(err, IsNil)
+		c.Check(might, Equals, t.might, Commentf("%s:%d-%d", t.chrom, t.start, t.end))
+	}
+	tbx.SetStrict(true)
+	_, err = tbx.MightOverlap("chr3", 0, 100)
+	c.Check(errors.Cause(err), Equals, ErrUnknownContig)
+}
+
+func (s *BixSuite) TestQueryName(c *C) {
+	tbx, err := New(writeTabix(c, "names.bed.gz", bedIndex(), scanLines(20)))
+	c.Assert(err, IsNil)
+	defer tbx.Close()
+
+	for _, t := range []struct {
+		name   string
+		flank  int
+		starts []uint32
+	}{
+		{"name5", 0, []uint32{40}},
+		{"name5", 10, []uint32{32, 40, 48}},
+		{"name0", 10, []uint32{0, 8}},
+	} {
+		it, err := tbx.QueryName("chr1", t.name, t.flank)
+		c.Assert(err, IsNil)
+		var starts []uint32
+		for _, r := range collect(c, it) {
+			starts = append(starts, r.Start())
+		}
+		c.Check(starts, DeepEquals, t.starts, Commentf("%s %d", t.name, t.flank))
+	}
+
+	_, err = tbx.QueryName("chr1", "missing", 0)
+	c.Check(err, ErrorMatches, "bix: no record named missing.*")
+	_, err = tbx.QueryName("chr1", "name1", -1)
+	c.Check(err, NotNil)
+
+	tbx.SetFeatureColumn(5)
+	it, err := tbx.QueryName("chr1", "7", 0)
+	c.Assert(err, IsNil)
+	recs := collect(c, it)
+	c.Assert(recs, HasLen, 1)
+	c.Check(recs[0].Start(), Equals, uint32(56))
+}
+
+func (s *BixSuite) TestMaxLineLength(c *C) {
+	tbx, err := New(writeTabix(c, "long.bed.gz", bedIndex(), []string{
+		"chr1\t10\t20\ta",
+		"chr1\t30\t40\t" + strings.Repeat("b", 100000),
+		"chr1\t50\t60\tc",
+	}))
+	c.Assert(err, IsNil)
+	defer tbx.Close()
+
+	it, err := tbx.Query(nil)
+	c.Assert(err, IsNil)
+	c.Check(collect(c, it), HasLen, 3)
+
+	tbx.SetMaxLineLength(1000)
+	for _, reuse := range []bool{false, true} {
+		tbx.SetReuseBuffers(reuse)
+		it, err = tbx.Query(interfaces.AsIPosition("chr1", 0, 100))
+		c.Assert(err, IsNil)
+		r, err := it.Next()
+		c.Assert(err, IsNil)
+		c.Check(r.Start(), Equals, uint32(10))
+		_, err = it.Next()
+		c.Check(errors.Cause(err), Equals, ErrLineTooLong)
+		c.Check(err, ErrorMatches, ".* longer than 1000 bytes in block at offset \\d+.*")
+		c.Check(it.Close(), IsNil)
+	}
+	_, err = tbx.Validate()
+	c.Check(errors.Cause(err), Equals, ErrLineTooLong)
+}
+
+func (s *BixSuite) TestQueryN(c *C) {
+	tbx, err := New("tests/csitest.bed.gz")
+	c.Assert(err, IsNil)
+	defer tbx.Close()
+
+	for _, n := range []int{0, 1, 2, 3, 10} {
+		it, err := tbx.QueryN(interfaces.AsIPosition("6", 12000, 12100), n)
+		c.Assert(err, IsNil)
+		exp := n
+		if exp > 3 {
+			exp = 3
+		}
+		c.Check(collect(c, it), HasLen, exp)
+	}
+}
+
+func (s *BixSuite) TestChecksum(c *C) {
+	tbx, err := New("tests/csitest.bed.gz")
+	c.Assert(err, IsNil)
+	defer tbx.Close()
+	tbx.SetChecksum(true)
+
+	it, err := tbx.Query(nil)
+	c.Assert(err, IsNil)
+	n := 0
+	for {
+		_, err := it.Next()
+		if err == io.EOF {
+			break
+		}
+		c.Assert(err, IsNil)
+		n++
+	}
+	c.Check(n, Equals, 9)
+	raw, err := os.ReadFile("tests/csitest.bed.gz")
+	c.Assert(err, IsNil)
+	c.Check(it.(Checksummer).Checksum(), Equals, crc32.ChecksumIEEE(raw))
+	c.Assert(it.Close(), IsNil)
+}
+
+func (s *BixSuite) TestMetaCharSkip(c *C) {
+	idx := bedIndex()
+	idx.MetaChar = '@'
+	idx.Skip = 1
+	tbx, err := New(writeTabix(c, "meta.bed.gz", idx, []string{
+		"chrom\tstart\tend",
+		"chr1\t10\t20",
+	}))
+	c.Assert(err, IsNil)
+	defer tbx.Close()
+	c.Check(tbx.MetaChar(), Equals, '@')
+	c.Check(tbx.SkipLines(), Equals, 1)
+}
+
+func (s *BixSuite) TestInterspersedHeader(c *C) {
+	tbx, err := New(writeTabix(c, "concat.bed.gz", bedIndex(), []string{
+		"#chrom\tstart\tend",
+		"chr1\t10\t20",
+		"chr1\t30\t40",
+		"#chrom\tstart\tend",
+		"# a comment",
+		"chr2\t5\t8",
+	}))
+	c.Assert(err, IsNil)
+	defer tbx.Close()
+
+	for _, region := range []interfaces.IPosition{nil, interfaces.AsIPosition("chr1", 0, 100), interfaces.AsIPosition("chr2", 0, 100)} {
+		it, err := tbx.Query(region)
+		c.Assert(err, IsNil)
+		for _, r := range collect(c, it) {
+			c.Check(r.Chrom(), Not(Equals), "#chrom")
+		}
+	}
+	it, err := tbx.Genome()
+	c.Assert(err, IsNil)
+	c.Check(collect(c, it), HasLen, 3)
+	counts, err := tbx.CountByColumn(nil, 1)
+	c.Assert(err, IsNil)
+	c.Check(counts, DeepEquals, map[string]int{"chr1": 2, "chr2": 1})
+}
+
+func (s *BixSuite) TestStrand(c *C) {
+	tbx, err := New(writeTabix(c, "strand.bed.gz", bedIndex(), []string{
+		"chr1\t10\t20\ta\t0\t+",
+		"chr1\t15\t25\tb\t0\t-",
+		"chr1\t18\t30\tc\t0\t.",
+		"chr1\t19\t22\td",
+	}))
+	c.Assert(err, IsNil)
+	defer tbx.Close()
+
+	names := func() []string {
+		it, err := tbx.Query(interfaces.AsIPosition("chr1", 0, 100))
+		c.Assert(err, IsNil)
+		var n []string
+		for _, r := range collect(c, it) {
+			n = append(n, string(r.(*parsers.Interval).Fields[3]))
+		}
+		return n
+	}
+	c.Check(names(), DeepEquals, []string{"a", "b", "c", "d"})
+	c.Assert(tbx.SetStrand('+'), IsNil)
+	c.Check(names(), DeepEquals, []string{"a"})
+	c.Assert(tbx.SetStrand('-'), IsNil)
+	c.Check(names(), DeepEquals, []string{"b"})
+	c.Check(tbx.SetStrand('x'), NotNil)
+
+	tbx.SetStrandColumn(4)
+	c.Assert(tbx.SetStrand(0), IsNil)
+	c.Check(names(), DeepEquals, []string{"a", "b", "c", "d"})
+}
+
+func (s *BixSuite) TestConcurrentQuery(c *C) {
+	idx := tabix.New()
+	idx.Format = FormatVCF
+	idx.NameColumn, idx.BeginColumn, idx.EndColumn = 1, 2, 0
+	idx.MetaChar = '#'
+	lines := []string{
+		"##fileformat=VCFv4.1",
+		"#CHROM\tPOS\tID\tREF\tALT\tQUAL\tFILTER\tINFO",
+	}
+	// the bad QUAL values make the parser record errors.
+	for i := 1; i < 500; i++ {
+		lines = append(lines, fmt.Sprintf("chr1\t%d\t.\tA\tG\tbad\tPASS\tDP=%d", i*10, i))
+	}
+	tbx, err := New(writeTabix(c, "conc.vcf.gz", idx, lines))
+	c.Assert(err, IsNil)
+	defer tbx.Close()
+
+	region := interfaces.AsIPosition("chr1", 0, 1<<29)
+	strs := func() []string {
+		it, err := tbx.Query(region)
+		if err != nil {
+			return nil
+		}
+		var out []string
+		for {
+			v, err := it.Next()
+			if err != nil {
+				break
+			}
+			out = append(out, v.(interfaces.IVariant).String())
+		}
+		it.Close()
+		return out
+	}
+	exp := strs()
+	c.Assert(len(exp) > 100, Equals, true)
+
+	results := make(chan []string)
+	for i := 0; i < 4; i++ {
+		go func() { results <- strs() }()
+	}
+	for i := 0; i < 4; i++ {
+		c.Check(<-results, DeepEquals, exp)
+	}
+}
+
+func (s *BixSuite) TestConcatenatedScan(c *C) {
+	p1 := writeTabix(c, "a.bed.gz", bedIndex(), []string{"chr1\t10\t20", "chr1\t30\t40"})
+	p2 := writeTabix(c, "b.bed.gz", bedIndex(), []string{"chr2\t10\t20", "chr2\t30\t40"})
+	a, err := os.ReadFile(p1)
+	c.Assert(err, IsNil)
+	b, err := os.ReadFile(p2)
+	c.Assert(err, IsNil)
+	path := filepath.Join(c.MkDir(), "cat.bed.gz")
+	c.Assert(os.WriteFile(path, append(a, b...), 0644), IsNil)
+	copyFile(c, p1+".tbi", path+".tbi")
+
+	tbx, err := New(path)
+	c.Assert(err, IsNil)
+	defer tbx.Close()
+	it, err := tbx.Query(nil)
+	c.Assert(err, IsNil)
+	recs := collect(c, it)
+	c.Assert(recs, HasLen, 4)
+	c.Check(recs[3].Chrom(), Equals, "chr2")
+}
+
+func (s *BixSuite) TestQueryChrom(c *C) {
+	tbx, err := New(writeTabix(c, "chrom.bed.gz", bedIndex(), []string{
+		"chr1\t10\t20",
+		"chr1\t300000000\t300000010",
+		"chr2\t5\t6",
+	}))
+	c.Assert(err, IsNil)
+	defer tbx.Close()
+
+	it, err := tbx.QueryChrom("1")
+	c.Assert(err, IsNil)
+	c.Check(collect(c, it), HasLen, 2)
+
+	it, err = tbx.QueryChrom("chr2")
+	c.Assert(err, IsNil)
+	c.Check(collect(c, it), HasLen, 1)
+
+	it, err = tbx.QueryChrom("chr3")
+	c.Assert(err, IsNil)
+	c.Check(collect(c, it), HasLen, 0)
+
+	tbx.SetStrict(true)
+	_, err = tbx.QueryChrom("chr3")
+	c.Check(errors.Cause(err), Equals, ErrUnknownContig)
+}
+
+func (s *BixSuite) TestInclusiveEnd(c *C) {
+	tbx, err := New("main/NA12878.wham.del.vcf.gz")
+	c.Assert(err, IsNil)
+	defer tbx.Close()
+
+	// the record is at POS 755638, which is 755637 0-based.
+	region := interfaces.AsIPosition("1", 755600, 755637)
+	it, err := tbx.Query(region)
+	c.Assert(err, IsNil)
+	c.Check(collect(c, it), HasLen, 0)
+
+	tbx.SetInclusiveEnd(true)
+	it, err = tbx.Query(region)
+	c.Assert(err, IsNil)
+	c.Check(collect(c, it), HasLen, 1)
+
+	// a single-base query, as for the samtools region 1:755638-755638.
+	it, err = tbx.Query(interfaces.AsIPosition("1", 755637, 755637))
+	c.Assert(err, IsNil)
+	recs := collect(c, it)
+	c.Assert(recs, HasLen, 1)
+	c.Check(recs[0].Start(), Equals, uint32(755637))
+
+	it, err = tbx.Query(interfaces.AsIPosition("1", 755636, 755636))
+	c.Assert(err, IsNil)
+	c.Check(collect(c, it), HasLen, 0)
+}
+
+func (s *BixSuite) TestSerial(c *C) {
+	tbx, err := NewSerial("tests/csitest.bed.gz")
+	c.Assert(err, IsNil)
+	defer tbx.Close()
+	f := tbx.file
+
+	for i := 0; i < 3; i++ {
+		it, err := tbx.Query(interfaces.AsIPosition("6", 0, maxEnd))
+		c.Assert(err, IsNil)
+		c.Check(collect(c, it), HasLen, 3)
+
+		it, err = tbx.FastQuery(interfaces.AsIPosition("1", 0, maxEnd))
+		c.Assert(err, IsNil)
+		c.Check(collect(c, it), HasLen, 1)
+
+		it, err = tbx.Query(nil)
+		c.Assert(err, IsNil)
+		c.Check(collect(c, it), HasLen, 9)
+	}
+	c.Check(tbx.file, Equals, f)
+
+	// a second query waits for the first iterator to be closed.
+	it, err := tbx.Query(interfaces.AsIPosition("6", 0, maxEnd))
+	c.Assert(err, IsNil)
+	done := make(chan int)
+	go func() {
+		it, err := tbx.Query(interfaces.AsIPosition("6", 0, maxEnd))
+		c.Check(err, IsNil)
+		done <- len(collect(c, it))
+	}()
+	c.Check(collect(c, it), HasLen, 3)
+	c.Check(<-done, Equals, 3)
+}
+
+func (s *BixSuite) TestMissingIndex(c *C) {
+	path := filepath.Join(c.MkDir(), "noindex.bed.gz")
+	copyFile(c, "tests/csitest.bed.gz", path)
+	_, err := New(path)
+	c.Assert(err, NotNil)
+	c.Check(err.Error(), Matches, "bix: no .tbi or .csi index found for .*noindex.bed.gz "+
+		`\(tried .*noindex.bed.gz.tbi and .*noindex.bed.gz.csi\)`)
+}
+
+func (s *BixSuite) TestAt(c *C) {
+	tbx, err := New(writeTabix(c, "at.bed.gz", bedIndex(), []string{
+		"chr1\t5\t50",
+		"chr1\t10\t11",
+		"chr1\t10\t20",
+		"chr1\t11\t12",
+	}))
+	c.Assert(err, IsNil)
+	defer tbx.Close()
+
+	it, err := tbx.At("chr1", 10)
+	c.Assert(err, IsNil)
+	recs := collect(c, it)
+	c.Assert(recs, HasLen, 2)
+	c.Check(recs[0].End(), Equals, uint32(11))
+	c.Check(recs[1].End(), Equals, uint32(20))
+
+	it, err = tbx.At("chr1", 12)
+	c.Assert(err, IsNil)
+	c.Check(collect(c, it), HasLen, 0)
+
+	_, err = tbx.At("chr1", -1)
+	c.Check(err, NotNil)
+}
+
+// scanLines returns n BED lines on chr1. No record crosses a 16kb tile
+// boundary as tabix.Index.Add panics on those.
+func scanLines(n int) []string {
+	lines := make([]string, n)
+	for i := range lines {
+		lines[i] = fmt.Sprintf("chr1\t%d\t%d\tname%d\t%d\t+", i*8, i*8+4, i, i%100)
+	}
+	return lines
+}
+
+func (s *BixSuite) TestReuseBuffers(c *C) {
+	tbx, err := New(writeTabix(c, "reuse.bed.gz", bedIndex(), scanLines(50)))
+	c.Assert(err, IsNil)
+	defer tbx.Close()
+
+	format := func(v interfaces.Relatable) string {
+		return fmt.Sprintf("%s:%d-%d:%s", v.Chrom(), v.Start(), v.End(), v.(*parsers.Interval).Fields[3])
+	}
+	for _, region := range []interfaces.IPosition{nil, interfaces.AsIPosition("chr1", 95, 205)} {
+		tbx.SetReuseBuffers(false)
+		it, err := tbx.Query(region)
+		c.Assert(err, IsNil)
+		var want []string
+		for _, v := range collect(c, it) {
+			want = append(want, format(v))
+		}
+
+		tbx.SetReuseBuffers(true)
+		it, err = tbx.Query(region)
+		c.Assert(err, IsNil)
+		var got []string
+		var first, last interfaces.Relatable
+		for {
+			v, err := it.Next()
+			if err == io.EOF {
+				break
+			}
+			c.Assert(err, IsNil)
+			if first == nil {
+				first = v
+			}
+			last = v
+			got = append(got, format(v))
+		}
+		c.Assert(it.Close(), IsNil)
+		c.Check(got, DeepEquals, want)
+		c.Check(len(got) > 1, Equals, true)
+		c.Check(first, Equals, last)
+	}
+}
+
+// refAltLines returns a header naming ref and alt columns and n records.
+func refAltLines(n int) []string {
+	lines := []string{"#chrom\tstart\tend\tref\talt"}
+	for i := 0; i < n; i++ {
+		lines = append(lines, fmt.Sprintf("chr1\t%d\t%d\tA\tT,G", i*8, i*8+1))
+	}
+	return lines
+}
+
+func (s *BixSuite) TestReuseBuffersRefAlt(c *C) {
+	tbx, err := New(writeTabix(c, "reuse-refalt.bed.gz", bedIndex(), refAltLines(50)))
+	c.Assert(err, IsNil)
+	defer tbx.Close()
+	tbx.SetReuseBuffers(true)
+
+	it, err := tbx.Query(interfaces.AsIPosition("chr1", 95, 205))
+	c.Assert(err, IsNil)
+	var got []string
+	var first, last interfaces.Relatable
+	for {
+		v, err := it.Next()
+		if err == io.EOF {
+			break
+		}
+		c.Assert(err, IsNil)
+		if first == nil {
+			first = v
+		}
+		last = v
+		ra := v.(*parsers.RefAltInterval)
+		got = append(got, fmt.Sprintf("%d-%d:%s>%s", v.Start(), v.End(), ra.Ref(), strings.Join(ra.Alt(), "|")))
+	}
+	c.Assert(it.Close(), IsNil)
+	c.Check(got, HasLen, 14)
+	c.Check(got[0], Equals, "96-97:A>T|G")
+	c.Check(got[13], Equals, "200-201:A>T|G")
+	c.Check(first, Equals, last)
+}
+
+func (s *BixSuite) benchmarkScan(c *C, lines []string, reuse bool) {
+	c.StopTimer()
+	tbx, err := New(writeTabix(c, "scan.bed.gz", bedIndex(), lines))
+	c.Assert(err, IsNil)
+	defer tbx.Close()
+	tbx.SetReuseBuffers(reuse)
+	c.StartTimer()
+	for i := 0; i < c.N; i++ {
+		it, err := tbx.Query(nil)
+		c.Assert(err, IsNil)
+		for {
+			_, err := it.Next()
+			if err == io.EOF {
+				break
+			}
+			c.Assert(err, IsNil)
+		}
+		it.Close()
+	}
+}
+
+func (s *BixSuite) BenchmarkScan(c *C)      { s.benchmarkScan(c, scanLines(2000), false) }
+func (s *BixSuite) BenchmarkScanReuse(c *C) { s.benchmarkScan(c, scanLines(2000), true) }
+
+func (s *BixSuite) BenchmarkScanRefAlt(c *C)      { s.benchmarkScan(c, refAltLines(2000), false) }
+func (s *BixSuite) BenchmarkScanRefAltReuse(c *C) { s.benchmarkScan(c, refAltLines(2000), true) }
+
+func (s *BixSuite) TestQueryReverse(c *C) {
+	tbx, err := New(writeTabix(c, "reverse.bed.gz", bedIndex(), scanLines(50)))
+	c.Assert(err, IsNil)
+	defer tbx.Close()
+
+	region := interfaces.AsIPosition("chr1", 95, 205)
+	it, err := tbx.Query(region)
+	c.Assert(err, IsNil)
+	fwd := collect(c, it)
+	c.Assert(len(fwd) > 1, Equals, true)
+
+	// records are kept separately even if buffers are reused.
+	tbx.SetReuseBuffers(true)
+	it, err = tbx.QueryReverse(region)
+	c.Assert(err, IsNil)
+	rev := collect(c, it)
+	c.Assert(rev, HasLen, len(fwd))
+	for i, v := range rev {
+		w := fwd[len(fwd)-1-i]
+		c.Check(v.Start(), Equals, w.Start())
+		c.Check(v.End(), Equals, w.End())
+		c.Check(v.(*parsers.Interval).Fields[3], DeepEquals, w.(*parsers.Interval).Fields[3])
+	}
+
+	it, err = tbx.QueryReverse(interfaces.AsIPosition("chr2", 0, 10))
+	c.Assert(err, IsNil)
+	c.Check(collect(c, it), HasLen, 0)
+}
+
+func (s *BixSuite) TestIsVCF(c *C) {
+	for path, want := range map[string]bool{
+		"tests/csitest.bed.gz":         false,
+		"main/NA12878.wham.del.vcf.gz": true,
+	} {
+		tbx, err := New(path)
+		c.Assert(err, IsNil)
+		c.Check(tbx.IsVCF(), Equals, want, Commentf(path))
+		tbx.Close()
+	}
+}
+
+type failWriter struct{}
+
+func (failWriter) Write(p []byte) (int, error) { return 0, errors.New("write failed") }
+
+func (s *BixSuite) TestWriteVCFHeader(c *C) {
+	tbx, err := New("main/NA12878.wham.del.vcf.gz")
+	c.Assert(err, IsNil)
+	defer tbx.Close()
+	tbx.AddInfoToHeader("ANNO", "1", "Integer", "an added field")
+
+	var buf bytes.Buffer
+	c.Assert(tbx.WriteVCFHeader(&buf), IsNil)
+	c.Check(buf.String(), Matches, `(?s)##fileformat=VCF.*##INFO=<ID=ANNO,Number=1,Type=Integer,Description="an added field">.*\n#CHROM\t[^\n]*\n`)
+
+	rdr, err := vcfgo.NewReader(&buf, true)
+	c.Assert(err, IsNil)
+	c.Check(rdr.Header.Infos["ANNO"], NotNil)
+	c.Check(rdr.Header.SampleNames, DeepEquals, tbx.VReader.Header.SampleNames)
+
+	c.Check(errors.Cause(tbx.WriteVCFHeader(failWriter{})), ErrorMatches, "write failed")
+
+	bed, err := New("tests/csitest.bed.gz")
+	c.Assert(err, IsNil)
+	defer bed.Close()
+	c.Check(bed.WriteVCFHeader(&buf), NotNil)
+}
+
+func (s *BixSuite) TestScanByContig(c *C) {
+	tbx, err := New(writeTabix(c, "scan.bed.gz", bedIndex(), []string{
+		"chr1\t10\t20",
+		"chr1\t30\t40",
+		"chr1\t50\t60",
+		"chr2\t5\t8",
+		"chrX\t1\t2",
+		"chrX\t3\t4",
+	}))
+	c.Assert(err, IsNil)
+	defer tbx.Close()
+	tbx.SetReuseBuffers(true)
+
+	counts := make(map[string]int)
+	var order []string
+	c.Assert(tbx.ScanByContig(func(chrom string, it interfaces.RelatableIterator) error {
+		order = append(order, chrom)
+		for {
+			v, err := it.Next()
+			if err == io.EOF {
+				return nil
+			} else if err != nil {
+				return err
+			}
+			c.Check(v.Chrom(), Equals, chrom)
+			counts[chrom]++
+		}
+	}), IsNil)
+	c.Check(order, DeepEquals, []string{"chr1", "chr2", "chrX"})
+	c.Check(counts, DeepEquals, map[string]int{"chr1": 3, "chr2": 1, "chrX": 2})
+
+	// the records that are not read are skipped.
+	var firsts []uint32
+	c.Assert(tbx.ScanByContig(func(chrom string, it interfaces.RelatableIterator) error {
+		v, err := it.Next()
+		c.Assert(err, IsNil)
+		firsts = append(firsts, v.Start())
+		return it.Close()
+	}), IsNil)
+	c.Check(firsts, DeepEquals, []uint32{10, 5, 1})
+
+	stop := errors.New("stop")
+	order = nil
+	err = tbx.ScanByContig(func(chrom string, it interfaces.RelatableIterator) error {
+		order = append(order, chrom)
+		if chrom == "chr2" {
+			return stop
+		}
+		return nil
+	})
+	c.Check(err, Equals, stop)
+	c.Check(order, DeepEquals, []string{"chr1", "chr2"})
+}
+
+func (s *BixSuite) TestGenome(c *C) {
+	tbx, err := New("tests/csitest.bed.gz")
+	c.Assert(err, IsNil)
+	defer tbx.Close()
+
+	it, err := tbx.Query(nil)
+	c.Assert(err, IsNil)
+	want := collect(c, it)
+
+	it, err = tbx.Genome()
+	c.Assert(err, IsNil)
+	got := collect(c, it)
+	c.Assert(got, HasLen, len(want))
+	for i := range got {
+		c.Check(got[i].Chrom(), Equals, want[i].Chrom())
+		c.Check(got[i].Start(), Equals, want[i].Start())
+	}
+	c.Check(got[0].Chrom(), Equals, tbx.Names()[0])
+
+	// trailing data that is not indexed is not returned.
+	path := writeTabix(c, "genome.bed.gz", bedIndex(), []string{"chr1\t10\t20", "chr2\t5\t6"})
+	f, err := os.OpenFile(path, os.O_APPEND|os.O_WRONLY, 0)
+	c.Assert(err, IsNil)
+	w := bgzf.NewWriter(f, 1)
+	_, err = w.Write([]byte("chr3\t1\t2\n"))
+	c.Assert(err, IsNil)
+	c.Assert(w.Close(), IsNil)
+	c.Assert(f.Close(), IsNil)
+
+	bed, err := New(path)
+	c.Assert(err, IsNil)
+	defer bed.Close()
+	it, err = bed.Genome()
+	c.Assert(err, IsNil)
+	c.Check(collect(c, it), HasLen, 2)
+	it, err = bed.Query(nil)
+	c.Assert(err, IsNil)
+	c.Check(collect(c, it), HasLen, 3)
+}
+
+func (s *BixSuite) TestNoEndColumn(c *C) {
+	idx := tabix.New()
+	idx.NameColumn, idx.BeginColumn, idx.EndColumn = 1, 2, 0
+	idx.MetaChar = '#'
+	tbx, err := New(writeTabix(c, "pos.txt.gz", idx, []string{
+		"#chrom\tpos\tscore",
+		"chr1\t10\t0.5",
+		"chr1\t20\t0.7",
+		"chr1\t21\t0.9",
+	}))
+	c.Assert(err, IsNil)
+	defer tbx.Close()
+	c.Assert(tbx.IsVCF(), Equals, false)
+
+	it, err := tbx.Query(interfaces.AsIPosition("chr1", 19, 20))
+	c.Assert(err, IsNil)
+	recs := collect(c, it)
+	c.Assert(recs, HasLen, 1)
+	c.Check(recs[0].Start(), Equals, uint32(19))
+	c.Check(recs[0].End(), Equals, uint32(20))
+
+	it, err = tbx.Query(interfaces.AsIPosition("chr1", 10, 19))
+	c.Assert(err, IsNil)
+	c.Check(collect(c, it), HasLen, 0)
+
+	it, err = tbx.Query(nil)
+	c.Assert(err, IsNil)
+	c.Check(collect(c, it), HasLen, 3)
+}
+
+// wideVCF writes a VCF with n records, each with the given number of samples.
+func wideVCF(c *C, n, samples int) string {
+	idx := tabix.New()
+	idx.NameColumn, idx.BeginColumn, idx.EndColumn = 1, 2, 0
+	idx.MetaChar = '#'
+	var hdr bytes.Buffer
+	hdr.WriteString("#CHROM\tPOS\tID\tREF\tALT\tQUAL\tFILTER\tINFO\tFORMAT")
+	for i := 0; i < samples; i++ {
+		fmt.Fprintf(&hdr, "\tS%d", i)
+	}
+	lines := []string{"##fileformat=VCFv4.1", `##FORMAT=<ID=GT,Number=1,Type=String,Description="Genotype">`, hdr.String()}
+	gts := strings.Repeat("\t0/1", samples)
+	for i := 0; i < n; i++ {
+		lines = append(lines, fmt.Sprintf("chr1\t%d\t.\tA\tT\t50\tPASS\t.\tGT%s", 100+i*10, gts))
+	}
+	return writeTabix(c, "wide.vcf.gz", idx, lines)
+}
+
+func (s *BixSuite) TestBufferSize(c *C) {
+	tbx, err := New(wideVCF(c, 5, 2000))
+	c.Assert(err, IsNil)
+	defer tbx.Close()
+
+	for _, size := range []int{0, 16, 1 << 16} {
+		tbx.SetBufferSize(size)
+		it, err := tbx.Query(nil)
+		c.Assert(err, IsNil)
+		c.Check(collect(c, it), HasLen, 5)
+		it, err = tbx.Query(interfaces.AsIPosition("chr1", 105, 125))
+		c.Assert(err, IsNil)
+		recs := collect(c, it)
+		c.Assert(recs, HasLen, 2)
+		c.Check(recs[0].Start(), Equals, uint32(109))
+	}
+}
+
+func (s *BixSuite) benchmarkWideVCF(c *C, size int) {
+	c.StopTimer()
+	tbx, err := New(wideVCF(c, 50, 50000))
+	c.Assert(err, IsNil)
+	defer tbx.Close()
+	tbx.SetBufferSize(size)
+	c.StartTimer()
+	for i := 0; i < c.N; i++ {
+		it, err := tbx.Query(nil)
+		c.Assert(err, IsNil)
+		for {
+			_, err := it.Next()
+			if err == io.EOF {
+				break
+			}
+			c.Assert(err, IsNil)
+		}
+		it.Close()
+	}
+}
+
+func (s *BixSuite) BenchmarkWideVCF(c *C)         { s.benchmarkWideVCF(c, 0) }
+func (s *BixSuite) BenchmarkWideVCFBuffered(c *C) { s.benchmarkWideVCF(c, 1<<20) }
+
+// benchmarkVCFInfo scans a VCF with many INFO fields, getting each of them
+// from every record if get is true.
+func (s *BixSuite) benchmarkVCFInfo(c *C, get bool) {
+	c.StopTimer()
+	idx := tabix.New()
+	idx.NameColumn, idx.BeginColumn, idx.EndColumn = 1, 2, 0
+	idx.MetaChar = '#'
+	lines := []string{"##fileformat=VCFv4.1"}
+	var info []string
+	for i := 0; i < 20; i++ {
+		lines = append(lines, fmt.Sprintf(`##INFO=<ID=F%d,Number=A,Type=Float,Description="field %d">`, i, i))
+		info = append(info, fmt.Sprintf("F%d=0.%d", i, i))
+	}
+	lines = append(lines, "#CHROM\tPOS\tID\tREF\tALT\tQUAL\tFILTER\tINFO")
+	for i := 0; i < 2000; i++ {
+		lines = append(lines, fmt.Sprintf("chr1\t%d\t.\tA\tT\t50\tPASS\t%s", 100+i*10, strings.Join(info, ";")))
+	}
+	tbx, err := New(writeTabix(c, "info.vcf.gz", idx, lines))
+	c.Assert(err, IsNil)
+	defer tbx.Close()
+	c.StartTimer()
+	for i := 0; i < c.N; i++ {
+		it, err := tbx.Query(nil)
+		c.Assert(err, IsNil)
+		for {
+			v, err := it.Next()
+			if err == io.EOF {
+				break
+			}
+			c.Assert(err, IsNil)
+			if get {
+				info := v.(interfaces.IVariant).Info()
+				for _, k := range info.Keys() {
+					_, err := info.Get(k)
+					c.Assert(err, IsNil)
+				}
+			}
+		}
+		it.Close()
+	}
+}
+
+func (s *BixSuite) BenchmarkVCFScan(c *C)     { s.benchmarkVCFInfo(c, false) }
+func (s *BixSuite) BenchmarkVCFScanInfo(c *C) { s.benchmarkVCFInfo(c, true) }
+
+// BenchmarkDenseVCFRegions queries small regions of a dense VCF with many
+// samples. Most of the lines read for each region start before it, and only
+// the first 8 fields of those are found to check their bounds.
+func (s *BixSuite) BenchmarkDenseVCFRegions(c *C) {
+	c.StopTimer()
+	idx := tabix.New()
+	idx.NameColumn, idx.BeginColumn, idx.EndColumn = 1, 2, 0
+	idx.MetaChar = '#'
+	header := "#CHROM\tPOS\tID\tREF\tALT\tQUAL\tFILTER\tINFO\tFORMAT"
+	var samples []string
+	for i := 0; i < 100; i++ {
+		header += fmt.Sprintf("\ts%d", i)
+		samples = append(samples, "0/1")
+	}
+	lines := []string{"##fileformat=VCFv4.1", header}
+	for i := 0; i < 5000; i++ {
+		lines = append(lines, fmt.Sprintf("chr1\t%d\t.\tA\tT\t50\tPASS\tDP=10\tGT\t%s", 100+i*3, strings.Join(samples, "\t")))
+	}
+	// a real index has few chunks, rather than one for each line.
+	tbx, err := New(writeTabixBlocks(c, "dense.vcf.gz", idx, lines, 100))
+	c.Assert(err, IsNil)
+	defer tbx.Close()
+	c.StartTimer()
+	for i := 0; i < c.N; i++ {
+		start := 100 + (i*997)%14000
+		it, err := tbx.Query(interfaces.AsIPosition("chr1", start, start+10))
+		c.Assert(err, IsNil)
+		c.Assert(collect(c, it), Not(HasLen), 0)
+	}
+}
+
+func (s *BixSuite) TestParsePos(c *C) {
+	for _, f := range []string{"1", "0", "123456789", "007", "-5", "+5", "", "1.5", "12a", "99999999999999999999"} {
+		want, wantErr := strconv.Atoi(f)
+		got, err := parsePos([]byte(f))
+		c.Check(got, Equals, want, Commentf("%q", f))
+		c.Check(err == nil, Equals, wantErr == nil, Commentf("%q", f))
+	}
+}
+
+func (s *BixSuite) TestReadHeader(c *C) {
+	for _, t := range []struct {
+		skip   int
+		lines  []string
+		header int
+		first  string
+	}{
+		{0, []string{"a\n", "b\n"}, 0, "a\n"},
+		{0, []string{"#a\n", "#b\n", "c\n", "#d\n"}, 2, "c\n"},
+		{1, []string{"#a\n", "b\n", "c\n"}, 1, "b\n"},
+		{2, []string{"a\n", "#b\n", "#c\n", "d\n"}, 3, "d\n"},
+		{2, []string{"#a\n", "#b\n", "c\n"}, 2, "c\n"},
+		{3, []string{"a\n", "b\n"}, 2, ""},
+		{0, []string{"#a\n", "b"}, 1, "b"},
+		{0, []string{"#a"}, 1, ""},
+		{0, nil, 0, ""},
+		{0, []string{"#a\n", "\n", "#b\n", "\r\n", "c\n"}, 4, "c\n"},
+		{1, []string{"\n", "\n", "c\n"}, 2, "c\n"},
+	} {
+		h, first, err := readHeader(bufio.NewReader(strings.NewReader(strings.Join(t.lines, ""))), t.skip, '#')
+		c.Assert(err, IsNil)
+		var want []string
+		for i, l := range t.lines[:t.header] {
+			if i < t.skip || strings.TrimSpace(l) != "" {
+				want = append(want, l)
+			}
+		}
+		c.Check(h, DeepEquals, want, Commentf("%v", t.lines))
+		c.Check(first, Equals, t.first, Commentf("%v", t.lines))
+	}
+}
+
+func (s *BixSuite) TestSkipAndMetaChar(c *C) {
+	idx := bedIndex()
+	idx.Skip = 2
+	tbx, err := New(writeTabix(c, "skipmeta.bed.gz", idx, []string{
+		"#track name=x",
+		"browser position chr1",
+		"#comment",
+		"#chrom\tstart\tend\tref\talt",
+		"chr1\t10\t20\tA\tT",
+		"chr1\t30\t40\tC\tG",
+	}))
+	c.Assert(err, IsNil)
+	defer tbx.Close()
+
+	it, err := tbx.Query(nil)
+	c.Assert(err, IsNil)
+	recs := collect(c, it)
+	c.Assert(recs, HasLen, 2)
+	c.Check(recs[0].Start(), Equals, uint32(10))
+	// the ref and alt columns are found from the last header line.
+	c.Check(recs[0].(*parsers.RefAltInterval).Ref(), Equals, "A")
+
+	it, err = tbx.Query(interfaces.AsIPosition("chr1", 0, 100))
+	c.Assert(err, IsNil)
+	c.Check(collect(c, it), HasLen, 2)
+}
+
+func (s *BixSuite) TestZeroWidthRegion(c *C) {
+	tbx, err := New(writeTabix(c, "point.bed.gz", bedIndex(), []string{
+		"chr1\t10\t20",
+		"chr1\t25\t30",
+	}))
+	c.Assert(err, IsNil)
+	defer tbx.Close()
+	m, err := tbx.LoadMemory()
+	c.Assert(err, IsNil)
+
+	for _, t := range []struct {
+		pos    uint32
+		starts []uint32
+	}{
+		{9, nil},
+		{10, []uint32{10}},
+		{15, []uint32{10}},
+		{22, nil},
+		{25, []uint32{25}},
+		{29, []uint32{25}},
+	} {
+		region := interfaces.AsIPosition("chr1", int(t.pos), int(t.pos))
+		for _, q := range []func(interfaces.IPosition) (interfaces.RelatableIterator, error){tbx.Query, tbx.FastQuery, m.Query} {
+			it, err := q(region)
+			c.Assert(err, IsNil)
+			var starts []uint32
+			for _, r := range collect(c, it) {
+				starts = append(starts, r.Start())
+			}
+			c.Check(starts, DeepEquals, t.starts, Commentf("%d", t.pos))
+		}
+	}
+}
+
+func (s *BixSuite) TestClosed(c *C) {
+	tbx, err := New("main/NA12878.wham.del.vcf.gz")
+	c.Assert(err, IsNil)
+	region := interfaces.AsIPosition("1", 0, maxEnd)
+	open, err := tbx.Query(region)
+	c.Assert(err, IsNil)
+	fast, err := tbx.FastQuery(region)
+	c.Assert(err, IsNil)
+	c.Assert(fast.Close(), IsNil)
+
+	c.Assert(tbx.Close(), IsNil)
+	c.Assert(tbx.Close(), IsNil)
+	c.Check(tbx.VReader.Header.SampleNames, Not(HasLen), 0)
+	// an iterator from before Close has its own file.
+	c.Check(collect(c, open), Not(HasLen), 0)
+
+	_, err = tbx.Query(region)
+	c.Check(errors.Cause(err), Equals, ErrClosed)
+	c.Check(err, ErrorMatches, ".*use of closed Bix")
+	_, err = tbx.FastQuery(region)
+	c.Check(errors.Cause(err), Equals, ErrClosed)
+	_, err = tbx.QueryRaw(region)
+	c.Check(errors.Cause(err), Equals, ErrClosed)
+	_, err = tbx.Clone()
+	c.Check(errors.Cause(err), Equals, ErrClosed)
+	_, err = tbx.ChunkedReader("1", 0, 1000)
+	c.Check(errors.Cause(err), Equals, ErrClosed)
+
+	serial, err := NewSerial("main/NA12878.wham.del.vcf.gz")
+	c.Assert(err, IsNil)
+	c.Assert(serial.Close(), IsNil)
+	_, err = serial.FastQuery(region)
+	c.Check(errors.Cause(err), Equals, ErrClosed)
+}
+
+func (s *BixSuite) TestClone(c *C) {
+	tbx, err := New("main/NA12878.wham.del.vcf.gz")
+	c.Assert(err, IsNil)
+	tbx.SetStrict(true)
+	region := interfaces.AsIPosition("1", 0, maxEnd)
+	it, err := tbx.Query(region)
+	c.Assert(err, IsNil)
+	want := len(collect(c, it))
+
+	var clones []*Bix
+	for i := 0; i < 4; i++ {
+		cl, err := tbx.Clone()
+		c.Assert(err, IsNil)
+		c.Check(cl.Index, Equals, tbx.Index)
+		c.Check(cl.VReader, Not(Equals), tbx.VReader)
+		clones = append(clones, cl)
+	}
+	c.Assert(tbx.Close(), IsNil)
+	_, err = clones[0].Query(interfaces.AsIPosition("nope", 0, 10))
+	c.Check(errors.Cause(err), Equals, ErrUnknownContig)
+
+	done := make(chan int)
+	for _, cl := range clones {
+		go func(cl *Bix) {
+			defer cl.Close()
+			it, err := cl.FastQuery(region)
+			c.Check(err, IsNil)
+			done <- len(collect(c, it))
+		}(cl)
+	}
+	for range clones {
+		c.Check(<-done, Equals, want)
+	}
+}
+
+func (s *BixSuite) TestQueryFilter(c *C) {
+	tbx, err := New("main/test.query.vcf.gz")
+	c.Assert(err, IsNil)
+	defer tbx.Close()
+
+	it, err := tbx.Query(nil)
+	c.Assert(err, IsNil)
+	all := collect(c, it)
+
+	var want []interfaces.Relatable
+	for _, v := range all {
+		if v.(interfaces.IVariant).Ref() == "A" {
+			want = append(want, v)
+		}
+	}
+	c.Assert(len(want) > 0 && len(want) < len(all), Equals, true)
+
+	it, err = tbx.QueryFilter(nil, func(toks [][]byte) bool { return string(toks[3]) == "A" })
+	c.Assert(err, IsNil)
+	got := collect(c, it)
+	c.Assert(got, HasLen, len(want))
+	for i := range got {
+		c.Check(got[i].Start(), Equals, want[i].Start())
+	}
+
+	it, err = tbx.QueryFilter(interfaces.AsIPosition("chr1", 0, maxEnd), func(toks [][]byte) bool { return false })
+	c.Assert(err, IsNil)
+	c.Check(collect(c, it), HasLen, 0)
+}
+
+func (s *BixSuite) TestCRLF(c *C) {
+	tbx, err := New(writeTabix(c, "crlf.bed.gz", bedIndex(), []string{
+		"#chrom\tstart\tend\tname\tscore\r",
+		"chr1\t10\t20\ta\t100\r",
+		"chr1\t30\t40\tb\t200\r",
+	}))
+	c.Assert(err, IsNil)
+	defer tbx.Close()
+
+	for _, reuse := range []bool{false, true} {
+		tbx.SetReuseBuffers(reuse)
+		for _, region := range []interfaces.IPosition{nil, interfaces.AsIPosition("chr1", 0, 100)} {
+			it, err := tbx.Query(region)
+			c.Assert(err, IsNil)
+			var scores []string
+			for {
+				v, err := it.Next()
+				if err == io.EOF {
+					break
+				}
+				c.Assert(err, IsNil)
+				scores = append(scores, string(v.(*parsers.Interval).Fields[4]))
+			}
+			c.Assert(it.Close(), IsNil)
+			c.Check(scores, DeepEquals, []string{"100", "200"})
+		}
+	}
+}
+
+func (s *BixSuite) TestSetWorkers(c *C) {
+	path := writeTabix(c, "workers.bed.gz", bedIndex(), scanLines(10))
+	var workers []int
+	open := func(r io.Reader, n int) (BlockReader, error) {
+		workers = append(workers, n)
+		return newBgzfReader(r, n)
+	}
+	tbx, err := NewWithBlockReader(path, path+".tbi", open, 1)
+	c.Assert(err, IsNil)
+	defer tbx.Close()
+	c.Check(tbx.NumWorkers(), Equals, 1)
+	c.Check(tbx.SetWorkers(0), NotNil)
+
+	it, err := tbx.FastQuery(interfaces.AsIPosition("chr1", 0, 20))
+	c.Assert(err, IsNil)
+	c.Check(tbx.SetWorkers(2), ErrorMatches, "bix: cannot set workers .* while a query is open")
+	collect(c, it)
+	c.Assert(tbx.SetWorkers(2), IsNil)
+	c.Check(tbx.NumWorkers(), Equals, 2)
+
+	workers = nil
+	it, err = tbx.FastQuery(interfaces.AsIPosition("chr1", 0, 20))
+	c.Assert(err, IsNil)
+	c.Check(collect(c, it), HasLen, 3)
+	it, err = tbx.Query(interfaces.AsIPosition("chr1", 0, 20))
+	c.Assert(err, IsNil)
+	c.Check(collect(c, it), HasLen, 3)
+	c.Check(workers, DeepEquals, []int{2, 2})
+
+	serial, err := NewSerial(path)
+	c.Assert(err, IsNil)
+	defer serial.Close()
+	it, err = serial.Query(interfaces.AsIPosition("chr1", 0, 20))
+	c.Assert(err, IsNil)
+	c.Check(serial.SetWorkers(2), NotNil)
+	it.Close()
+	c.Check(serial.SetWorkers(2), IsNil)
+	it, err = serial.Query(interfaces.AsIPosition("chr1", 0, 20))
+	c.Assert(err, IsNil)
+	c.Check(collect(c, it), HasLen, 3)
+}
+
+func (s *BixSuite) TestSetColumns(c *C) {
+	idx := tabix.New()
+	idx.NameColumn, idx.BeginColumn, idx.EndColumn = 1, 2, 0
+	idx.ZeroBased = true
+	tbx, err := New(writeTabix(c, "columns.txt.gz", idx, []string{
+		"chr1\t10\t20\tA\tT",
+		"chr1\t30\t40\tC\tG",
+	}))
+	c.Assert(err, IsNil)
+	defer tbx.Close()
+
+	it, err := tbx.Query(nil)
+	c.Assert(err, IsNil)
+	recs := collect(c, it)
+	c.Assert(recs, HasLen, 2)
+	c.Check(recs[0].End(), Equals, uint32(11))
+	_, ok := recs[0].(interfaces.IRefAlt)
+	c.Check(ok, Equals, false)
+
+	tbx.SetColumns(1, 2, 3, true)
+	tbx.SetRefAlt(4, 5)
+	c.Check(tbx.EndColumn(), Equals, 3)
+	it, err = tbx.Query(interfaces.AsIPosition("chr1", 30, 31))
+	c.Assert(err, IsNil)
+	recs = collect(c, it)
+	c.Assert(recs, HasLen, 1)
+	c.Check(recs[0].End(), Equals, uint32(40))
+	c.Check(recs[0].(interfaces.IRefAlt).Ref(), Equals, "C")
+	c.Check(recs[0].(interfaces.IRefAlt).Alt(), DeepEquals, []string{"G"})
+
+	tbx.SetRefAlt(0, 0)
+	it, err = tbx.Query(nil)
+	c.Assert(err, IsNil)
+	recs = collect(c, it)
+	c.Check(recs[0].End(), Equals, uint32(20))
+	_, ok = recs[0].(interfaces.IRefAlt)
+	c.Check(ok, Equals, false)
+}
+
+func (s *BixSuite) TestSetZeroBased(c *C) {
+	// a BED file indexed as if its starts were 1-based.
+	idx := bedIndex()
+	idx.ZeroBased = false
+	tbx, err := New(writeTabix(c, "flagged.bed.gz", idx, []string{
+		"chr1\t10\t20",
+		"chr1\t30\t40",
+	}))
+	c.Assert(err, IsNil)
+	defer tbx.Close()
+
+	starts := func(start, end int) []uint32 {
+		it, err := tbx.Query(interfaces.AsIPosition("chr1", start, end))
+		c.Assert(err, IsNil)
+		var starts []uint32
+		for _, r := range collect(c, it) {
+			starts = append(starts, r.Start())
+		}
+		return starts
+	}
+	c.Check(tbx.ZeroBased(), Equals, false)
+	c.Check(starts(9, 10), DeepEquals, []uint32{9})
+
+	tbx.SetZeroBased(true)
+	c.Check(tbx.ZeroBased(), Equals, true)
+	c.Check(starts(9, 10), IsNil)
+	c.Check(starts(10, 11), DeepEquals, []uint32{10})
+	c.Check(starts(0, 100), DeepEquals, []uint32{10, 30})
+
+	vcf, err := New("main/NA12878.wham.del.vcf.gz")
+	c.Assert(err, IsNil)
+	defer vcf.Close()
+	vcf.SetZeroBased(true)
+	c.Check(vcf.ZeroBased(), Equals, false)
+}
+
+func (s *BixSuite) TestNormalized(c *C) {
+	idx := tabix.New()
+	idx.NameColumn, idx.BeginColumn, idx.EndColumn = 1, 2, 3
+	tbx, err := New(writeTabix(c, "onebased.txt.gz", idx, []string{
+		"chr1\t11\t20",
+		"chr1\t31\t40",
+	}))
+	c.Assert(err, IsNil)
+	defer tbx.Close()
+
+	for _, raw := range []bool{false, true} {
+		tbx.SetRawLines(raw)
+		it, err := tbx.Query(nil)
+		c.Assert(err, IsNil)
+		recs := collect(c, it)
+		c.Assert(recs, HasLen, 2)
+		start, err := tbx.NormalizedStart(recs[1])
+		c.Assert(err, IsNil)
+		c.Check(start, Equals, 30)
+		end, err := tbx.NormalizedEnd(recs[1])
+		c.Assert(err, IsNil)
+		c.Check(end, Equals, 40)
+		c.Check(uint32(start), Equals, recs[1].Start())
+	}
+
+	tbx.SetColumns(1, 2, 0, true)
+	start, err := tbx.NormalizedStart(parsers.NewInterval("chr1", 0, 0, [][]byte{[]byte("chr1"), []byte("5")}, 0, nil))
+	c.Assert(err, IsNil)
+	c.Check(start, Equals, 5)
+	_, err = tbx.NormalizedEnd(parsers.NewInterval("chr1", 0, 0, [][]byte{[]byte("chr1"), []byte("x")}, 0, nil))
+	c.Check(err, NotNil)
+}
+
+func (s *BixSuite) TestBlankLines(c *C) {
+	tbx, err := New(writeTabix(c, "blank.bed.gz", bedIndex(), []string{
+		"#chrom\tstart\tend",
+		"",
+		"chr1\t10\t20",
+		"",
+		"chr1\t30\t40",
+		"\r",
+	}))
+	c.Assert(err, IsNil)
+	defer tbx.Close()
+
+	it, err := tbx.Query(nil)
+	c.Assert(err, IsNil)
+	c.Check(collect(c, it), HasLen, 2)
+	it, err = tbx.Query(interfaces.AsIPosition("chr1", 0, 100))
+	c.Assert(err, IsNil)
+	c.Check(collect(c, it), HasLen, 2)
+}
+
+func (s *BixSuite) TestLineFilter(c *C) {
+	tbx, err := New(writeTabix(c, "track.bed.gz", bedIndex(), []string{
+		"browser position chr1:1-100",
+		"track name=test",
+		"chr1\t10\t20",
+		"track name=other",
+		"chr1\t30\t40",
+	}))
+	c.Assert(err, IsNil)
+	defer tbx.Close()
+
+	tbx.SetLineFilter(func(line []byte) bool {
+		return bytes.HasPrefix(line, []byte("track ")) || bytes.HasPrefix(line, []byte("browser "))
+	})
+	it, err := tbx.Query(nil)
+	c.Assert(err, IsNil)
+	c.Check(collect(c, it), HasLen, 2)
+	it, err = tbx.Query(interfaces.AsIPosition("chr1", 15, 35))
+	c.Assert(err, IsNil)
+	c.Check(collect(c, it), HasLen, 2)
+}
+
+func (s *BixSuite) TestUncompressedIndex(c *C) {
+	for _, src := range []string{"tests/csitest.bed.gz", "main/NA12878.wham.del.vcf.gz"} {
+		ext := ".tbi"
+		if exists(src + ".csi") {
+			ext = ".csi"
+		}
+		path := filepath.Join(c.MkDir(), filepath.Base(src))
+		copyFile(c, src, path)
+
+		f, err := os.Open(src + ext)
+		c.Assert(err, IsNil)
+		r, err := bgzf.NewReader(f, 1)
+		c.Assert(err, IsNil)
+		out, err := os.Create(path + ext)
+		c.Assert(err, IsNil)
+		_, err = io.Copy(out, r)
+		c.Assert(err, IsNil)
+		c.Assert(out.Close(), IsNil)
+		c.Assert(f.Close(), IsNil)
+
+		want, err := New(src)
+		c.Assert(err, IsNil)
+		tbx, err := New(path)
+		c.Assert(err, IsNil)
+		c.Check(tbx.Names(), DeepEquals, want.Names())
+		it, err := tbx.Query(interfaces.AsIPosition(want.Names()[0], 0, maxEnd))
+		c.Assert(err, IsNil)
+		c.Check(len(collect(c, it)) > 0, Equals, true)
+		want.Close()
+		tbx.Close()
+	}
+}
+
+func (s *BixSuite) TestBookended(c *C) {
+	tbx, err := New(writeTabix(c, "bookended.bed.gz", bedIndex(), []string{
+		"chr1\t10\t20\ta",
+		"chr1\t20\t30\tb",
+		"chr1\t25\t25\tc",
+		"chr1\t30\t40\td",
+	}))
+	c.Assert(err, IsNil)
+	defer tbx.Close()
+
+	names := func(q interface {
+		Query(interfaces.IPosition) (interfaces.RelatableIterator, error)
+	}) []string {
+		it, err := q.Query(interfaces.AsIPosition("chr1", 20, 30))
+		c.Assert(err, IsNil)
+		var names []string
+		for _, r := range collect(c, it) {
+			names = append(names, string(recordFields(r)[3]))
+		}
+		return names
+	}
+
+	// as for bedtools intersect, records that only touch the region are
+	// not returned.
+	mem, err := tbx.LoadMemory()
+	c.Assert(err, IsNil)
+	c.Check(names(tbx), DeepEquals, []string{"b", "c"})
+	c.Check(names(mem), DeepEquals, []string{"b", "c"})
+
+	tbx.SetBookended(true)
+	mem, err = tbx.LoadMemory()
+	c.Assert(err, IsNil)
+	c.Check(names(tbx), DeepEquals, []string{"a", "b", "c", "d"})
+	c.Check(names(mem), DeepEquals, []string{"a", "b", "c", "d"})
+}
+
+func (s *BixSuite) TestZeroLengthFeatures(c *C) {
+	tbx, err := New(writeTabix(c, "points.bed.gz", bedIndex(), []string{
+		"chr1\t10\t10\ta",
+		"chr1\t20\t20\tb",
+		"chr1\t30\t30\tc",
+	}))
+	c.Assert(err, IsNil)
+	defer tbx.Close()
+	mem, err := tbx.LoadMemory()
+	c.Assert(err, IsNil)
+
+	for _, t := range []struct {
+		start, end int
+		starts     []uint32
+	}{
+		// a point is in a region that includes its position...
+		{10, 11, []uint32{10}},
+		{10, 20, []uint32{10}},
+		{10, 21, []uint32{10, 20}},
+		{0, 100, []uint32{10, 20, 30}},
+		// ...and not in one that ends at it or starts after it.
+		{0, 10, nil},
+		{11, 20, nil},
+		{31, 40, nil},
+		// a zero-width region at the point.
+		{20, 20, []uint32{20}},
+	} {
+		for _, q := range []interface {
+			Query(interfaces.IPosition) (interfaces.RelatableIterator, error)
+		}{tbx, mem} {
+			it, err := q.Query(interfaces.AsIPosition("chr1", t.start, t.end))
+			c.Assert(err, IsNil)
+			var starts []uint32
+			for _, r := range collect(c, it) {
+				c.Check(r.End(), Equals, r.Start())
+				starts = append(starts, r.Start())
+			}
+			c.Check(starts, DeepEquals, t.starts, Commentf("%T %d-%d", q, t.start, t.end))
+		}
+	}
+}
+
+func (s *BixSuite) TestSymbolicEnd(c *C) {
+	idx := tabix.New()
+	idx.NameColumn, idx.BeginColumn, idx.EndColumn = 1, 2, 0
+	idx.MetaChar = '#'
+	tbx, err := New(writeTabix(c, "sv.vcf.gz", idx, []string{
+		"##fileformat=VCFv4.2",
+		"#CHROM\tPOS\tID\tREF\tALT\tQUAL\tFILTER\tINFO",
+		"1\t100\t.\tA\t<DEL>\t50\tPASS\tEND=5000;SVTYPE=DEL",
+		"1\t200\t.\tC\t<INS>\t50\tPASS\tSVTYPE=INS;SVLEN=400;END=200",
+		"1\t300\t.\tG\t<NON_REF>\t50\tPASS\tEND=7000",
+		"1\t400\t.\tT\tC\t50\tPASS\tDP=10",
+	}))
+	c.Assert(err, IsNil)
+	defer tbx.Close()
+
+	it, err := tbx.Query(nil)
+	c.Assert(err, IsNil)
+	var ends []uint32
+	for _, r := range collect(c, it) {
+		ends = append(ends, r.End())
+	}
+	c.Check(ends, DeepEquals, []uint32{5000, 200, 7000, 400})
+
+	// vcfgo takes the end of a <DEL> from END, so it is not wrapped.
+	vit, err := tbx.QueryVariants(nil)
+	c.Assert(err, IsNil)
+	var types []string
+	ends = ends[:0]
+	for {
+		v, err := vit.Next()
+		if err == io.EOF {
+			break
+		}
+		c.Assert(err, IsNil)
+		ends = append(ends, vit.End(v))
+	}
+	c.Assert(vit.Close(), IsNil)
+	c.Check(ends, DeepEquals, []uint32{5000, 200, 7000, 400})
+	it, err = tbx.Query(nil)
+	c.Assert(err, IsNil)
+	for _, r := range collect(c, it) {
+		types = append(types, fmt.Sprintf("%T", r.(interfaces.VarWrap).IVariant))
+	}
+	c.Check(types, DeepEquals, []string{"*vcfgo.Variant", "*bix.EndVariant", "*bix.EndVariant", "*vcfgo.Variant"})
+
+	for _, t := range []struct {
+		start, end int
+		starts     []uint32
+	}{
+		{4000, 4001, []uint32{99, 299}},
+		{4999, 5000, []uint32{99, 299}},
+		{5000, 5001, []uint32{299}},
+		{250, 260, []uint32{99}},
+		{7000, 7001, nil},
+	} {
+		it, err := tbx.Query(interfaces.AsIPosition("1", t.start, t.end))
+		c.Assert(err, IsNil)
+		var starts []uint32
+		for _, r := range collect(c, it) {
+			starts = append(starts, r.Start())
+		}
+		c.Check(starts, DeepEquals, t.starts, Commentf("%d-%d", t.start, t.end))
+	}
+}
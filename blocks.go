@@ -0,0 +1,129 @@
+package bix
+
+import (
+	"encoding/binary"
+	"io"
+	"os"
+
+	"github.com/pkg/errors"
+)
+
+// BlockOffsets returns the file offset of the start of each bgzf block that
+// holds data, in order. Empty blocks, such as the EOF marker, are not
+// included. The offsets can be used to split the file into byte ranges that
+// start on block boundaries, for example to be read in parallel with a
+// bgzf.Reader seeked to bgzf.Offset{File: offset}. Note that records may
+// span blocks. Only the block headers are read, so this is fast even for
+// large files.
+func (tbx *Bix) BlockOffsets() ([]int64, error) {
+	if tbx.multi != nil {
+		return nil, errMulti
+	}
+	f, err := openData(tbx.path)
+	if err != nil {
+		return nil, errors.Wrapf(err, "bix: error opening %s", tbx.path)
+	}
+	defer f.Close()
+
+	var offsets []int64
+	var off int64
+	for {
+		size, err := tbx.blockSizeAt(f, off)
+		if err == io.EOF {
+			return offsets, nil
+		} else if err != nil {
+			return nil, err
+		}
+		// the uncompressed size is the last 4 bytes of the block.
+		isize := make([]byte, 4)
+		if _, err := f.ReadAt(isize, off+size-4); err != nil {
+			return nil, errors.Wrapf(err, "bix: error reading block at %d in %s", off, tbx.path)
+		}
+		if binary.LittleEndian.Uint32(isize) != 0 {
+			offsets = append(offsets, off)
+		}
+		off += size
+	}
+}
+
+// blockSizeAt returns the compressed size of the bgzf block at off in f, read
+// from its header, or io.EOF if off is the end of f.
+func (tbx *Bix) blockSizeAt(f dataFile, off int64) (int64, error) {
+	hdr := make([]byte, 12)
+	if _, err := f.ReadAt(hdr, off); err == io.EOF {
+		return 0, io.EOF
+	} else if err != nil {
+		return 0, errors.Wrapf(err, "bix: error reading block at %d in %s", off, tbx.path)
+	}
+	if hdr[0] != 31 || hdr[1] != 139 || hdr[3]&4 == 0 {
+		return 0, errors.Errorf("bix: invalid bgzf block at %d in %s", off, tbx.path)
+	}
+	extra := make([]byte, binary.LittleEndian.Uint16(hdr[10:]))
+	if _, err := f.ReadAt(extra, off+12); err != nil {
+		return 0, errors.Wrapf(err, "bix: error reading block at %d in %s", off, tbx.path)
+	}
+	size := blockSize(extra)
+	if size == 0 {
+		return 0, errors.Errorf("bix: missing block size at %d in %s", off, tbx.path)
+	}
+	return size, nil
+}
+
+// IsBGZF returns true if the file at path is bgzf compressed, as is needed
+// for indexed queries. Only the header of the first block is read. A file
+// compressed with plain gzip, rather than bgzip, is not bgzf.
+func IsBGZF(path string) (bool, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return false, errors.Wrapf(err, "bix: error opening %s", path)
+	}
+	defer f.Close()
+	_, bgzf, err := compression(f)
+	if err != nil {
+		return false, errors.Wrapf(err, "bix: error reading %s", path)
+	}
+	return bgzf, nil
+}
+
+// compression reports whether r starts with a gzip member and whether that
+// member has the BC subfield of a bgzf block.
+func compression(r io.ReaderAt) (gzip, bgzf bool, err error) {
+	hdr := make([]byte, 12)
+	if n, err := r.ReadAt(hdr, 0); n < len(hdr) {
+		if err == io.EOF {
+			err = nil
+		}
+		return n >= 2 && hdr[0] == 31 && hdr[1] == 139, false, err
+	}
+	if hdr[0] != 31 || hdr[1] != 139 {
+		return false, false, nil
+	}
+	if hdr[3]&4 == 0 {
+		// no extra field.
+		return true, false, nil
+	}
+	extra := make([]byte, binary.LittleEndian.Uint16(hdr[10:]))
+	if _, err := r.ReadAt(extra, 12); err != nil {
+		if err == io.EOF {
+			err = nil
+		}
+		return true, false, err
+	}
+	return true, blockSize(extra) != 0, nil
+}
+
+// blockSize returns the total size of a bgzf block from the BC subfield of
+// its gzip extra field or 0 if it is not present.
+func blockSize(extra []byte) int64 {
+	for len(extra) >= 4 {
+		n := int(binary.LittleEndian.Uint16(extra[2:]))
+		if extra[0] == 'B' && extra[1] == 'C' && n == 2 && len(extra) >= 6 {
+			return int64(binary.LittleEndian.Uint16(extra[4:])) + 1
+		}
+		if len(extra) < 4+n {
+			break
+		}
+		extra = extra[4+n:]
+	}
+	return 0
+}
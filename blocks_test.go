@@ -0,0 +1,107 @@
+package bix
+
+import (
+	"compress/gzip"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/biogo/hts/bgzf"
+	. "gopkg.in/check.v1"
+)
+
+func (s *BixSuite) TestBlockOffsets(c *C) {
+	// writeTabix puts each line in its own block.
+	lines := scanLines(20)
+	path := writeTabix(c, "blocks.bed.gz", bedIndex(), lines)
+	tbx, err := New(path)
+	c.Assert(err, IsNil)
+	defer tbx.Close()
+
+	offsets, err := tbx.BlockOffsets()
+	c.Assert(err, IsNil)
+	c.Assert(offsets, HasLen, len(lines))
+	c.Check(offsets[0], Equals, int64(0))
+
+	f, err := os.Open(path)
+	c.Assert(err, IsNil)
+	defer f.Close()
+	r, err := bgzf.NewReader(f, 1)
+	c.Assert(err, IsNil)
+	defer r.Close()
+	buf := make([]byte, 1024)
+	for i, off := range offsets {
+		c.Assert(r.Seek(bgzf.Offset{File: off}), IsNil)
+		r.Blocked = true
+		n, err := r.Read(buf)
+		if err != io.EOF {
+			c.Assert(err, IsNil)
+		}
+		c.Check(string(buf[:n]), Equals, lines[i]+"\n")
+	}
+
+	// a copy with text after its last block.
+	badPath := filepath.Join(c.MkDir(), "bad.bed.gz")
+	b, err := os.ReadFile(path)
+	c.Assert(err, IsNil)
+	c.Assert(os.WriteFile(badPath, append(b, "chr1\t1\t2\tnot bgzf\n"...), 0644), IsNil)
+	copyFile(c, path+".tbi", badPath+".tbi")
+	bad, err := New(badPath)
+	c.Assert(err, IsNil)
+	defer bad.Close()
+	_, err = bad.BlockOffsets()
+	c.Check(err, ErrorMatches, fmt.Sprintf("bix: invalid bgzf block at %d in .*", len(b)))
+}
+
+func (s *BixSuite) TestResumeFrom(c *C) {
+	lines := append([]string{"#chrom\tstart\tend"}, scanLines(10)...)
+	tbx, err := New(writeTabix(c, "resume.bed.gz", bedIndex(), lines))
+	c.Assert(err, IsNil)
+	defer tbx.Close()
+
+	offsets, err := tbx.BlockOffsets()
+	c.Assert(err, IsNil)
+	c.Assert(offsets, HasLen, len(lines))
+
+	it, err := tbx.ResumeFrom(bgzf.Offset{File: offsets[4]})
+	c.Assert(err, IsNil)
+	recs := collect(c, it)
+	c.Assert(recs, HasLen, 7)
+	c.Check(recs[0].Start(), Equals, uint32(24))
+
+	it, err = tbx.ResumeFrom(bgzf.Offset{})
+	c.Assert(err, IsNil)
+	c.Check(collect(c, it), HasLen, 10)
+}
+
+func (s *BixSuite) TestIsBGZF(c *C) {
+	path := writeTabix(c, "bgzf.bed.gz", bedIndex(), scanLines(3))
+	ok, err := IsBGZF(path)
+	c.Assert(err, IsNil)
+	c.Check(ok, Equals, true)
+
+	// the same data compressed with plain gzip.
+	plain := filepath.Join(c.MkDir(), "plain.bed.gz")
+	f, err := os.Create(plain)
+	c.Assert(err, IsNil)
+	w := gzip.NewWriter(f)
+	_, err = w.Write([]byte(strings.Join(scanLines(3), "\n") + "\n"))
+	c.Assert(err, IsNil)
+	c.Assert(w.Close(), IsNil)
+	c.Assert(f.Close(), IsNil)
+	copyFile(c, path+".tbi", plain+".tbi")
+
+	ok, err = IsBGZF(plain)
+	c.Assert(err, IsNil)
+	c.Check(ok, Equals, false)
+	_, err = New(plain)
+	c.Check(err, ErrorMatches, "bix: .* is gzip but not bgzf; indexed queries unavailable.*")
+
+	ok, err = IsBGZF("bix.go")
+	c.Assert(err, IsNil)
+	c.Check(ok, Equals, false)
+	_, err = IsBGZF(plain + ".missing")
+	c.Check(err, NotNil)
+}
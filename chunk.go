@@ -0,0 +1,226 @@
+package bix
+
+import (
+	"io"
+	"sort"
+
+	"github.com/biogo/hts/bgzf"
+	"github.com/brentp/irelate/interfaces"
+	"github.com/pkg/errors"
+)
+
+// BlockReader reads a block-compressed file that is addressed by virtual
+// offsets as for bgzf. A *bgzf.Reader is used by default; other
+// implementations allow files with a tabix or CSI index but a different
+// block compression to be queried. See NewWithBlockReader.
+type BlockReader interface {
+	// Read must not return bytes from more than one block in a call and
+	// returns io.EOF only at the end of the data.
+	io.Reader
+	io.Closer
+	// Seek moves to the given virtual offset.
+	Seek(bgzf.Offset) error
+	// LastChunk returns the virtual offsets spanned by the last Read or the
+	// offset of the last Seek.
+	LastChunk() bgzf.Chunk
+	// BlockLen returns the number of bytes left in the current block.
+	BlockLen() int
+}
+
+// BlockReaderFunc returns a BlockReader for the compressed data in r, using
+// the given number of workers if that is supported.
+type BlockReaderFunc func(r io.Reader, workers int) (BlockReader, error)
+
+// newBgzfReader is the default BlockReaderFunc.
+func newBgzfReader(r io.Reader, workers int) (BlockReader, error) {
+	bg, err := bgzf.NewReader(r, workers)
+	if err != nil {
+		return nil, err
+	}
+	bg.Blocked = true
+	return bgzfBlocks{bg}, nil
+}
+
+// bgzfBlocks is a *bgzf.Reader in Blocked mode that does not return io.EOF
+// at the end of each block.
+type bgzfBlocks struct {
+	*bgzf.Reader
+}
+
+func (b bgzfBlocks) Read(p []byte) (int, error) {
+	n, err := b.Reader.Read(p)
+	if err == io.EOF && n > 0 {
+		err = nil
+	}
+	return n, err
+}
+
+// openBlocks returns a BlockReader for r.
+func (tbx *Bix) openBlocks(r io.Reader) (BlockReader, error) {
+	open := tbx.blockReader
+	if open == nil {
+		open = newBgzfReader
+	}
+	br, err := open(r, tbx.workers)
+	if err != nil {
+		return nil, errors.Wrapf(err, "bix: error creating new block reader for %v", tbx.path)
+	}
+	return br, nil
+}
+
+// chunkReader reads the given chunks from a BlockReader. It is adapted from
+// index.ChunkReader in biogo/hts, which requires a *bgzf.Reader.
+type chunkReader struct {
+	r      BlockReader
+	chunks []bgzf.Chunk
+}
+
+func newChunkReader(r BlockReader, chunks []bgzf.Chunk) (*chunkReader, error) {
+	chunks = mergeChunks(chunks)
+	if len(chunks) != 0 {
+		if err := r.Seek(chunks[0].Begin); err != nil {
+			return nil, err
+		}
+	}
+	return &chunkReader{r: r, chunks: chunks}, nil
+}
+
+func (r *chunkReader) Read(p []byte) (int, error) {
+	if len(r.chunks) == 0 {
+		return 0, io.EOF
+	}
+	last := r.r.LastChunk()
+	if vOffset(last.End) >= vOffset(r.chunks[0].End) {
+		return 0, io.EOF
+	}
+
+	// limit the read to the end of the current chunk. reads do not cross
+	// the end of a block.
+	want := int(r.chunks[0].End.Block)
+	if r.chunks[0].End.Block == 0 && r.chunks[0].End.File > last.End.File {
+		// the chunk ends at the start of a later block.
+		want = r.r.BlockLen()
+	}
+	var cursor int
+	if last.End.File == r.chunks[0].End.File {
+		// the chunk ends in this block.
+		cursor = int(last.End.Block)
+	}
+	n, err := r.r.Read(p[:min(len(p), want-cursor)])
+	if err != nil {
+		if n != 0 && err == io.EOF {
+			err = nil
+		}
+		return n, err
+	}
+
+	// move to the next chunk if we are at the end of this one or made no
+	// progress.
+	this := r.r.LastChunk()
+	if (len(p) != 0 && this == last) || vOffset(this.End) >= vOffset(r.chunks[0].End) {
+		r.chunks = r.chunks[1:]
+		if len(r.chunks) == 0 {
+			return n, io.EOF
+		}
+		err = r.r.Seek(r.chunks[0].Begin)
+	}
+	return n, err
+}
+
+// MultiChunkedReader returns a reader over the chunks for each of regions in
+// turn, as for ChunkedReader. The lines are not filtered to the regions and
+// a line in more than one region may be repeated. Like FastQuery, it uses
+// the file handle of tbx, so it must not be used concurrently with other
+// reads from tbx.
+func (tbx *Bix) MultiChunkedReader(regions ...interfaces.IPosition) (io.ReadCloser, error) {
+	for _, r := range regions {
+		if err := checkRegion(r); err != nil {
+			return nil, err
+		}
+	}
+	if tbx.multi != nil {
+		return nil, errMulti
+	}
+	if err := tbx.init(); err != nil {
+		return nil, err
+	}
+	return &multiChunkReader{tbx: tbx, regions: regions}, nil
+}
+
+// multiChunkReader reads the chunks of each region in turn. The reader for a
+// region is only made once the previous one is done, as each seeks in the
+// shared BlockReader.
+type multiChunkReader struct {
+	tbx     *Bix
+	regions []interfaces.IPosition
+	cur     io.ReadCloser
+}
+
+func (m *multiChunkReader) Read(p []byte) (int, error) {
+	for {
+		if m.cur == nil {
+			if len(m.regions) == 0 {
+				return 0, io.EOF
+			}
+			r := m.regions[0]
+			m.regions = m.regions[1:]
+			var err error
+			m.cur, err = m.tbx.ChunkedReader(r.Chrom(), m.tbx.regionStart(r), m.tbx.regionEnd(r))
+			if err != nil {
+				return 0, err
+			}
+		}
+		n, err := m.cur.Read(p)
+		if err == io.EOF {
+			m.cur.Close()
+			m.cur = nil
+			if n == 0 {
+				continue
+			}
+			err = nil
+		}
+		return n, err
+	}
+}
+
+func (m *multiChunkReader) Close() error {
+	m.regions = nil
+	if m.cur == nil {
+		return nil
+	}
+	err := m.cur.Close()
+	m.cur = nil
+	return err
+}
+
+// mergeChunks returns the chunks sorted by their start with overlapping
+// chunks merged, so that no part of the file is read twice and no record is
+// returned twice. The indexes usually merge chunks already.
+func mergeChunks(chunks []bgzf.Chunk) []bgzf.Chunk {
+	if len(chunks) < 2 {
+		return chunks
+	}
+	sorted := make([]bgzf.Chunk, len(chunks))
+	copy(sorted, chunks)
+	sort.Slice(sorted, func(i, j int) bool { return vOffset(sorted[i].Begin) < vOffset(sorted[j].Begin) })
+	merged := sorted[:1]
+	for _, c := range sorted[1:] {
+		last := &merged[len(merged)-1]
+		if vOffset(c.Begin) > vOffset(last.End) {
+			merged = append(merged, c)
+		} else if vOffset(c.End) > vOffset(last.End) {
+			last.End = c.End
+		}
+	}
+	return merged
+}
+
+// Close releases the BlockReader without closing it.
+func (r *chunkReader) Close() error {
+	r.r = nil
+	return nil
+}
+
+func vOffset(o bgzf.Offset) int64 {
+	return o.File<<16 | int64(o.Block)
+}
@@ -0,0 +1,115 @@
+package bix
+
+import (
+	"io"
+	"io/ioutil"
+
+	"github.com/biogo/hts/bgzf"
+	"github.com/brentp/irelate/interfaces"
+	. "gopkg.in/check.v1"
+)
+
+// countingBlocks is a BlockReader that counts calls to Read.
+type countingBlocks struct {
+	BlockReader
+	reads *int
+}
+
+func (c countingBlocks) Read(p []byte) (int, error) {
+	*c.reads++
+	return c.BlockReader.Read(p)
+}
+
+func (s *BixSuite) TestBlockReader(c *C) {
+	want, err := New("tests/csitest.bed.gz")
+	c.Assert(err, IsNil)
+	defer want.Close()
+
+	var reads, opens int
+	open := func(r io.Reader, workers int) (BlockReader, error) {
+		opens++
+		br, err := newBgzfReader(r, workers)
+		return countingBlocks{br, &reads}, err
+	}
+	tbx, err := NewWithBlockReader("tests/csitest.bed.gz", "tests/csitest.bed.gz.csi", open, 1)
+	c.Assert(err, IsNil)
+	defer tbx.Close()
+
+	for _, region := range []interfaces.IPosition{nil, interfaces.AsIPosition("6", 0, maxEnd), interfaces.AsIPosition("1", 0, 100000)} {
+		it, err := want.Query(region)
+		c.Assert(err, IsNil)
+		w := collect(c, it)
+		it, err = tbx.Query(region)
+		c.Assert(err, IsNil)
+		g := collect(c, it)
+		c.Assert(g, HasLen, len(w))
+		for i := range g {
+			c.Check(g[i].Chrom(), Equals, w[i].Chrom())
+			c.Check(g[i].Start(), Equals, w[i].Start())
+		}
+	}
+	c.Check(opens, Equals, 4)
+	c.Check(reads > 0, Equals, true)
+}
+
+// overlapIndex returns the same, overlapping chunks for every query.
+type overlapIndex struct {
+	Index
+	chunks []bgzf.Chunk
+}
+
+func (o overlapIndex) Chunks(string, int, int) ([]bgzf.Chunk, error) {
+	return o.chunks, nil
+}
+
+func (s *BixSuite) TestOverlappingChunks(c *C) {
+	tbx, err := New(writeTabix(c, "overlap.bed.gz", bedIndex(), scanLines(12)))
+	c.Assert(err, IsNil)
+	defer tbx.Close()
+	blocks, err := tbx.BlockOffsets()
+	c.Assert(err, IsNil)
+	c.Assert(len(blocks) >= 10, Equals, true)
+
+	// each line is in its own block, so these chunks hold lines 0-5 and 3-8,
+	// the second chunk starting at a boundary inside the first.
+	at := func(i int) bgzf.Offset { return bgzf.Offset{File: blocks[i]} }
+	tbx.Index = overlapIndex{Index: tbx.Index, chunks: []bgzf.Chunk{
+		{Begin: at(3), End: at(9)},
+		{Begin: at(0), End: at(6)},
+		{Begin: at(3), End: at(6)},
+	}}
+	it, err := tbx.Query(interfaces.AsIPosition("chr1", 0, maxEnd))
+	c.Assert(err, IsNil)
+	var starts []uint32
+	for _, r := range collect(c, it) {
+		starts = append(starts, r.Start())
+	}
+	c.Check(starts, DeepEquals, []uint32{0, 8, 16, 24, 32, 40, 48, 56, 64})
+}
+
+func (s *BixSuite) TestMultiChunkedReader(c *C) {
+	tbx, err := New(writeTabix(c, "multi.bed.gz", bedIndex(), []string{
+		"chr1\t10\t20",
+		"chr1\t30\t40",
+		"chr2\t5\t8",
+		"chr3\t1\t2",
+	}))
+	c.Assert(err, IsNil)
+	defer tbx.Close()
+
+	r, err := tbx.MultiChunkedReader(
+		interfaces.AsIPosition("chr3", 0, 10),
+		interfaces.AsIPosition("chr4", 0, 10),
+		interfaces.AsIPosition("chr1", 25, 50),
+		interfaces.AsIPosition("chr2", 0, 10),
+	)
+	c.Assert(err, IsNil)
+	b, err := ioutil.ReadAll(r)
+	c.Assert(err, IsNil)
+	c.Assert(r.Close(), IsNil)
+	// the chunks for chr1 start from the first record in its 16kb window.
+	c.Check(string(b), Equals, "chr3\t1\t2\nchr1\t10\t20\nchr1\t30\t40\nchr2\t5\t8\n")
+
+	_, err = tbx.MultiChunkedReader(interfaces.AsIPosition("chr1", 10, 5))
+	c.Check(err, NotNil)
+}
@@ -0,0 +1,108 @@
+package bix
+
+import (
+	"github.com/brentp/irelate/interfaces"
+)
+
+// ClippedRecord is a record from QueryClipped whose Start and End are clamped
+// to the query region. The record's own coordinates are given by
+// OriginalStart and OriginalEnd, and the record itself by Record.
+type ClippedRecord struct {
+	interfaces.Relatable
+	start, end uint32
+}
+
+// Start returns the start of the record or of the region if it is later.
+func (r *ClippedRecord) Start() uint32 { return r.start }
+
+// End returns the end of the record or of the region if it is earlier.
+func (r *ClippedRecord) End() uint32 { return r.end }
+
+// OriginalStart returns the start of the record before it was clipped.
+func (r *ClippedRecord) OriginalStart() uint32 { return r.Relatable.Start() }
+
+// OriginalEnd returns the end of the record before it was clipped.
+func (r *ClippedRecord) OriginalEnd() uint32 { return r.Relatable.End() }
+
+// Record returns the record that was clipped.
+func (r *ClippedRecord) Record() interfaces.Relatable { return r.Relatable }
+
+// QueryClipped is like Query but each record is returned as a *ClippedRecord
+// with its start and end clamped to region, as for drawing the records in a
+// window. A record that ends before it starts once clipped, such as an
+// insertion book-ended to the region, has an end equal to its start. With a
+// nil region the records are not clipped.
+func (tbx *Bix) QueryClipped(region interfaces.IPosition) (interfaces.RelatableIterator, error) {
+	it, err := tbx.Query(region)
+	if err != nil {
+		return nil, err
+	}
+	c := &clipIterator{RelatableIterator: it, end: maxEnd}
+	if region != nil {
+		c.start, c.end = region.Start(), exclusiveEnd(region, tbx.inclusiveEnd, false)
+	}
+	return c, nil
+}
+
+type clipIterator struct {
+	interfaces.RelatableIterator
+	start, end uint32
+}
+
+func (c *clipIterator) Next() (interfaces.Relatable, error) {
+	v, err := c.RelatableIterator.Next()
+	if err != nil {
+		return nil, err
+	}
+	r := &ClippedRecord{Relatable: v, start: v.Start(), end: v.End()}
+	if r.start < c.start {
+		r.start = c.start
+	}
+	if r.end > c.end {
+		r.end = c.end
+	}
+	if r.end < r.start {
+		r.end = r.start
+	}
+	return r, nil
+}
+
+var _ interfaces.Relatable = (*ClippedRecord)(nil)
+
+// ContainedIterator returns the records of a query along with whether each
+// is contained in the region. See QueryContained.
+type ContainedIterator struct {
+	it         interfaces.RelatableIterator
+	start, end uint32
+}
+
+// QueryContained is like Query but the iterator also gives, for each record,
+// whether it is fully contained in region rather than only overlapping it at
+// an edge, as for drawing clipped records differently. With a nil region
+// every record is contained.
+func (tbx *Bix) QueryContained(region interfaces.IPosition) (*ContainedIterator, error) {
+	it, err := tbx.Query(region)
+	if err != nil {
+		return nil, err
+	}
+	c := &ContainedIterator{it: it, end: maxEnd}
+	if region != nil {
+		c.start, c.end = region.Start(), exclusiveEnd(region, tbx.inclusiveEnd, false)
+	}
+	return c, nil
+}
+
+// Next returns the next record and whether it starts and ends within the
+// region, or io.EOF when there are no more.
+func (c *ContainedIterator) Next() (interfaces.Relatable, bool, error) {
+	v, err := c.it.Next()
+	if err != nil {
+		return nil, false, err
+	}
+	return v, v.Start() >= c.start && v.End() <= c.end, nil
+}
+
+// Close closes the underlying query.
+func (c *ContainedIterator) Close() error {
+	return c.it.Close()
+}
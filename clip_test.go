@@ -0,0 +1,86 @@
+package bix
+
+import (
+	"io"
+
+	"github.com/brentp/irelate/interfaces"
+	. "gopkg.in/check.v1"
+)
+
+func (s *BixSuite) TestQueryClipped(c *C) {
+	tbx, err := New(writeTabix(c, "clip.bed.gz", bedIndex(), []string{
+		"chr1\t10\t50\ta",
+		"chr1\t20\t30\tb",
+		"chr1\t40\t200\tc",
+	}))
+	c.Assert(err, IsNil)
+	defer tbx.Close()
+
+	type span struct{ start, end, ostart, oend uint32 }
+	spans := func(region interfaces.IPosition) []span {
+		it, err := tbx.QueryClipped(region)
+		c.Assert(err, IsNil)
+		var spans []span
+		for _, r := range collect(c, it) {
+			cr := r.(*ClippedRecord)
+			c.Check(cr.Record().Start(), Equals, cr.OriginalStart())
+			spans = append(spans, span{cr.Start(), cr.End(), cr.OriginalStart(), cr.OriginalEnd()})
+		}
+		return spans
+	}
+
+	c.Check(spans(interfaces.AsIPosition("chr1", 25, 45)), DeepEquals, []span{
+		{25, 45, 10, 50},
+		{25, 30, 20, 30},
+		{40, 45, 40, 200},
+	})
+	c.Check(spans(interfaces.AsIPosition("chr1", 60, 100)), DeepEquals, []span{
+		{60, 100, 40, 200},
+	})
+	c.Check(spans(nil), DeepEquals, []span{
+		{10, 50, 10, 50},
+		{20, 30, 20, 30},
+		{40, 200, 40, 200},
+	})
+
+	tbx.SetInclusiveEnd(true)
+	c.Check(spans(interfaces.AsIPosition("chr1", 60, 99)), DeepEquals, []span{
+		{60, 100, 40, 200},
+	})
+}
+
+func (s *BixSuite) TestQueryContained(c *C) {
+	tbx, err := New(writeTabix(c, "contained.bed.gz", bedIndex(), []string{
+		"chr1\t10\t50\ta",
+		"chr1\t20\t30\tb",
+		"chr1\t25\t25\tc",
+		"chr1\t40\t200\td",
+	}))
+	c.Assert(err, IsNil)
+	defer tbx.Close()
+
+	contained := func(region interfaces.IPosition) map[string]bool {
+		it, err := tbx.QueryContained(region)
+		c.Assert(err, IsNil)
+		defer it.Close()
+		m := make(map[string]bool)
+		for {
+			v, in, err := it.Next()
+			if err == io.EOF {
+				return m
+			}
+			c.Assert(err, IsNil)
+			m[string(recordFields(v)[3])] = in
+		}
+	}
+	c.Check(contained(interfaces.AsIPosition("chr1", 15, 45)), DeepEquals,
+		map[string]bool{"a": false, "b": true, "c": true, "d": false})
+	c.Check(contained(interfaces.AsIPosition("chr1", 10, 50)), DeepEquals,
+		map[string]bool{"a": true, "b": true, "c": true, "d": false})
+	c.Check(contained(nil), DeepEquals,
+		map[string]bool{"a": true, "b": true, "c": true, "d": true})
+
+	tbx.SetInclusiveEnd(true)
+	c.Check(contained(interfaces.AsIPosition("chr1", 10, 49)), DeepEquals,
+		map[string]bool{"a": true, "b": true, "c": true, "d": false})
+}
@@ -12,6 +12,15 @@ import (
 	"github.com/biogo/hts/tabix"
 )
 
+// Values of the format field of a tabix or CSI index.
+const (
+	FormatGeneric = 0
+	FormatSAM     = 1
+	FormatVCF     = 2
+	// FormatZeroBased is set when the begin column is 0-based.
+	FormatZeroBased = 0x10000
+)
+
 // Index unifies CSI and tabix.
 type Index interface {
 	Chunks(string, int, int) ([]bgzf.Chunk, error)
@@ -21,6 +30,10 @@ type Index interface {
 	ZeroBased() bool
 	MetaChar() rune
 	Skip() int
+	NumRefs() int
+	Names() []string
+	// Format returns the format field from the index header.
+	Format() int
 }
 
 type tIndex struct{ *tabix.Index }
@@ -48,9 +61,22 @@ func (t tIndex) Skip() int {
 	return int(t.Index.Skip)
 }
 
+func (t tIndex) Format() int {
+	f := int(t.Index.Format)
+	if t.Index.ZeroBased {
+		f |= FormatZeroBased
+	}
+	return f
+}
+
+func (t tIndex) NumRefs() int {
+	return t.Index.NumRefs()
+}
+
 type cIndex struct {
 	*csi.Index
 	chroms      []string
+	format      int
 	nameColumn  int
 	beginColumn int
 	endColumn   int
@@ -82,6 +108,18 @@ func (c cIndex) Skip() int {
 	return c.skip
 }
 
+func (c cIndex) Format() int {
+	return c.format
+}
+
+func (c cIndex) NumRefs() int {
+	return len(c.chroms)
+}
+
+func (c cIndex) Names() []string {
+	return c.chroms
+}
+
 // StripChr removes the "chr" prefix if it is present
 func stripChr(c string) string {
 	if strings.HasPrefix(c, "chr") {
@@ -90,6 +128,9 @@ func stripChr(c string) string {
 	return c
 }
 
+// Chunks returns the chunks for the region. csi.Index computes bins with the
+// min shift and depth read from the index, so contigs longer than 2^29 are
+// supported if the index was built with settings that allow them.
 func (c cIndex) Chunks(chrom string, start int, end int) ([]bgzf.Chunk, error) {
 	idx := -1
 	chrom = stripChr(chrom)
@@ -111,6 +152,8 @@ func NewCSI(r io.Reader) (cIndex, error) {
 	}
 	aux := c.Auxilliary
 
+	ci.format = int(binary.LittleEndian.Uint32(aux[0:4]))
+	ci.zeroBased = ci.format&FormatZeroBased != 0
 	ci.nameColumn = int(binary.LittleEndian.Uint32(aux[4:8]))
 	ci.beginColumn = int(binary.LittleEndian.Uint32(aux[8:12]))
 	ci.endColumn = int(binary.LittleEndian.Uint32(aux[12:16]))
@@ -1,10 +1,18 @@
 package bix
 
 import (
+	"bytes"
 	"compress/gzip"
+	"encoding/binary"
 	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
 	"testing"
 
+	"github.com/biogo/hts/bgzf"
+	"github.com/biogo/hts/csi"
+	"github.com/brentp/irelate/interfaces"
 	. "gopkg.in/check.v1"
 )
 
@@ -32,8 +40,108 @@ func (s *BixSuite) TestReadCSI(c *C) {
 	c.Check(cs.BeginColumn(), Equals, 2)
 	c.Check(cs.EndColumn(), Equals, 3)
 	c.Check(cs.Skip(), Equals, 0)
+	c.Check(cs.Format(), Equals, FormatGeneric|FormatZeroBased)
+	c.Check(cs.ZeroBased(), Equals, true)
 	c.Check(cs.NumRefs(), Equals, len(cs.chroms))
 	c.Check(cs.MetaChar(), Equals, '#')
 	c.Check(cs.chroms, DeepEquals, []string{"1", "2", "3", "4", "5", "6", "9"})
 
 }
+
+type csiRecord struct {
+	id, start, end int
+}
+
+func (r csiRecord) RefID() int { return r.id }
+func (r csiRecord) Start() int { return r.start }
+func (r csiRecord) End() int   { return r.end }
+
+// writeCSI writes the BED lines to a bgzf file called name in a temporary
+// directory, with each line in its own block, and indexes it with a CSI
+// index with the given min shift and depth. It returns the path to the data
+// file.
+func writeCSI(c *C, name string, minShift, depth int, lines []string) string {
+	path := filepath.Join(c.MkDir(), name)
+	f, err := os.Create(path)
+	c.Assert(err, IsNil)
+	cw := &countWriter{w: f}
+	w := bgzf.NewWriter(cw, 1)
+
+	idx := csi.New(minShift, depth)
+	var names []string
+	for _, l := range lines {
+		begin := cw.n
+		_, err := w.Write([]byte(l + "\n"))
+		c.Assert(err, IsNil)
+		c.Assert(w.Flush(), IsNil)
+		c.Assert(w.Wait(), IsNil)
+		toks := strings.Split(l, "\t")
+		if len(names) == 0 || names[len(names)-1] != toks[0] {
+			names = append(names, toks[0])
+		}
+		s, err := strconv.Atoi(toks[1])
+		c.Assert(err, IsNil)
+		e, err := strconv.Atoi(toks[2])
+		c.Assert(err, IsNil)
+		chunk := bgzf.Chunk{Begin: bgzf.Offset{File: begin}, End: bgzf.Offset{File: cw.n}}
+		c.Assert(idx.Add(csiRecord{len(names) - 1, s, e}, chunk, true, true), IsNil)
+	}
+	c.Assert(w.Close(), IsNil)
+	c.Assert(f.Close(), IsNil)
+
+	// the tabix header: format, columns, meta char, skip and the names.
+	var aux bytes.Buffer
+	nm := []byte(strings.Join(names, "\x00") + "\x00")
+	for _, v := range []int32{FormatGeneric | FormatZeroBased, 1, 2, 3, '#', 0, int32(len(nm))} {
+		c.Assert(binary.Write(&aux, binary.LittleEndian, v), IsNil)
+	}
+	aux.Write(nm)
+	idx.Auxilliary = aux.Bytes()
+
+	fi, err := os.Create(path + ".csi")
+	c.Assert(err, IsNil)
+	wi := bgzf.NewWriter(fi, 1)
+	c.Assert(csi.WriteTo(wi, idx), IsNil)
+	c.Assert(wi.Close(), IsNil)
+	c.Assert(fi.Close(), IsNil)
+	return path
+}
+
+func (s *BixSuite) TestCSILongContig(c *C) {
+	// positions past 2^29 cannot be indexed with tabix or the default CSI
+	// settings.
+	tbx, err := New(writeCSI(c, "long.bed.gz", 16, 5, []string{
+		"chr1\t1000000\t1000100",
+		"chr1\t900000000\t900000100",
+		"chr1\t900001000\t900001100",
+		"chr1\t2000000000\t2000000100",
+		"chr2\t10\t20",
+	}))
+	c.Assert(err, IsNil)
+	defer tbx.Close()
+	c.Check(tbx.Names(), DeepEquals, []string{"chr1", "chr2"})
+
+	for _, t := range []struct {
+		start, end int
+		starts     []uint32
+	}{
+		{0, 2000000, []uint32{1000000}},
+		{899999000, 900000050, []uint32{900000000}},
+		{900000050, 900002000, []uint32{900000000, 900001000}},
+		{900001101, 1999999999, nil},
+		{1999999999, maxEnd, []uint32{2000000000}},
+		{0, maxEnd, []uint32{1000000, 900000000, 900001000, 2000000000}},
+	} {
+		it, err := tbx.Query(interfaces.AsIPosition("chr1", t.start, t.end))
+		c.Assert(err, IsNil)
+		var starts []uint32
+		for _, r := range collect(c, it) {
+			starts = append(starts, r.Start())
+		}
+		c.Check(starts, DeepEquals, t.starts, Commentf("%d-%d", t.start, t.end))
+	}
+	n, exact, err := tbx.EstimateRecords()
+	c.Assert(err, IsNil)
+	c.Check(exact, Equals, true)
+	c.Check(n, Equals, int64(5))
+}
@@ -0,0 +1,163 @@
+package bix
+
+import (
+	"fmt"
+	"io"
+	"strings"
+
+	"github.com/brentp/irelate/interfaces"
+)
+
+// Diff returns the records in region that are in tbx but not in other and
+// those in other but not in tbx. Records are matched by position and, for
+// VCF and other files with ref and alt, by ref and alt, otherwise by end;
+// contigs are matched allowing for a missing or extra "chr" prefix. Both
+// files are read in a single pass, so each must be sorted. A nil region
+// compares the entire files, a contig at a time. Records that appear more
+// than once are matched one for one.
+func (tbx *Bix) Diff(other *Bix, region interfaces.IPosition) (onlyLeft, onlyRight []interfaces.Relatable, err error) {
+	if region != nil {
+		return diffRegion(tbx, other, region, region)
+	}
+	seen := make(map[string]bool)
+	for _, name := range tbx.Names() {
+		left := interfaces.AsIPosition(name, 0, maxEnd)
+		o, ok := other.CanonicalContig(name)
+		if !ok {
+			recs, err := allRecords(tbx, left)
+			if err != nil {
+				return nil, nil, err
+			}
+			onlyLeft = append(onlyLeft, recs...)
+			continue
+		}
+		seen[o] = true
+		l, r, err := diffRegion(tbx, other, left, interfaces.AsIPosition(o, 0, maxEnd))
+		if err != nil {
+			return nil, nil, err
+		}
+		onlyLeft, onlyRight = append(onlyLeft, l...), append(onlyRight, r...)
+	}
+	for _, name := range other.Names() {
+		if seen[name] {
+			continue
+		}
+		recs, err := allRecords(other, interfaces.AsIPosition(name, 0, maxEnd))
+		if err != nil {
+			return nil, nil, err
+		}
+		onlyRight = append(onlyRight, recs...)
+	}
+	return onlyLeft, onlyRight, nil
+}
+
+// allRecords returns the records in region, which are not reused.
+func allRecords(tbx *Bix, region interfaces.IPosition) ([]interfaces.Relatable, error) {
+	bx, err := tbx.queryBixerator(region)
+	if err != nil {
+		return nil, err
+	}
+	defer bx.Close()
+	bx.keep = true
+	var recs []interfaces.Relatable
+	for {
+		v, err := bx.Next()
+		if err == io.EOF {
+			return recs, nil
+		} else if err != nil {
+			return nil, err
+		}
+		recs = append(recs, v)
+	}
+}
+
+// diffRegion is Diff for the region of a single contig, which may be named
+// differently in each file.
+func diffRegion(tbx, other *Bix, left, right interfaces.IPosition) (onlyLeft, onlyRight []interfaces.Relatable, err error) {
+	lit, err := tbx.queryBixerator(left)
+	if err != nil {
+		return nil, nil, err
+	}
+	defer lit.Close()
+	rit, err := other.queryBixerator(right)
+	if err != nil {
+		return nil, nil, err
+	}
+	defer rit.Close()
+	// the records are kept, so they must not be reused.
+	lit.keep, rit.keep = true, true
+
+	for {
+		lv, err := lit.Peek()
+		if err != nil && err != io.EOF {
+			return nil, nil, err
+		}
+		rv, err := rit.Peek()
+		if err != nil && err != io.EOF {
+			return nil, nil, err
+		}
+		if lv == nil && rv == nil {
+			return onlyLeft, onlyRight, nil
+		}
+		// the records at the next start in either file.
+		var pos uint32
+		if lv != nil && (rv == nil || lv.Start() <= rv.Start()) {
+			pos = lv.Start()
+		} else {
+			pos = rv.Start()
+		}
+		lg, err := takeAt(lit, pos)
+		if err != nil {
+			return nil, nil, err
+		}
+		rg, err := takeAt(rit, pos)
+		if err != nil {
+			return nil, nil, err
+		}
+
+		rkeys := make([]string, len(rg))
+		for j, v := range rg {
+			rkeys[j] = diffKey(v)
+		}
+		matched := make([]bool, len(rg))
+	left:
+		for _, v := range lg {
+			k := diffKey(v)
+			for j := range rg {
+				if !matched[j] && rkeys[j] == k {
+					matched[j] = true
+					continue left
+				}
+			}
+			onlyLeft = append(onlyLeft, v)
+		}
+		for j, v := range rg {
+			if !matched[j] {
+				onlyRight = append(onlyRight, v)
+			}
+		}
+	}
+}
+
+// takeAt returns the records from bx that start at pos.
+func takeAt(bx *bixerator, pos uint32) ([]interfaces.Relatable, error) {
+	var recs []interfaces.Relatable
+	for {
+		v, err := bx.Peek()
+		if err == io.EOF || (err == nil && v.Start() != pos) {
+			return recs, nil
+		} else if err != nil {
+			return nil, err
+		}
+		bx.Next()
+		recs = append(recs, v)
+	}
+}
+
+// diffKey returns the identity of v used by Diff, other than its contig.
+func diffKey(v interfaces.Relatable) string {
+	if ra, ok := v.(interfaces.IRefAlt); ok {
+		return fmt.Sprintf("%d\t%s\t%s", v.Start(), ra.Ref(), strings.Join(ra.Alt(), ","))
+	}
+	return fmt.Sprintf("%d\t%d", v.Start(), v.End())
+}
@@ -0,0 +1,75 @@
+package bix
+
+import (
+	"fmt"
+
+	"github.com/biogo/hts/tabix"
+	"github.com/brentp/irelate/interfaces"
+	. "gopkg.in/check.v1"
+)
+
+// diffVCF writes a VCF with a record for each of the "chrom:pos:ref:alt"
+// variants.
+func diffVCF(c *C, name string, variants ...string) *Bix {
+	idx := tabix.New()
+	idx.NameColumn, idx.BeginColumn, idx.EndColumn = 1, 2, 0
+	idx.MetaChar = '#'
+	lines := []string{"##fileformat=VCFv4.1", "#CHROM\tPOS\tID\tREF\tALT\tQUAL\tFILTER\tINFO"}
+	for _, v := range variants {
+		var chrom, ref, alt string
+		var pos int
+		_, err := fmt.Sscanf(v, "%s %d %s %s", &chrom, &pos, &ref, &alt)
+		c.Assert(err, IsNil)
+		lines = append(lines, fmt.Sprintf("%s\t%d\t.\t%s\t%s\t50\tPASS\t.", chrom, pos, ref, alt))
+	}
+	tbx, err := New(writeTabix(c, name, idx, lines))
+	c.Assert(err, IsNil)
+	return tbx
+}
+
+func (s *BixSuite) TestDiff(c *C) {
+	left := diffVCF(c, "left.vcf.gz", "1 100 A T", "1 200 A T", "1 200 A G", "1 300 A T", "2 10 C G")
+	defer left.Close()
+	right := diffVCF(c, "right.vcf.gz", "chr1 100 A T", "chr1 200 A G", "chr1 250 A C", "chr1 300 A T", "chr3 5 G A")
+	defer right.Close()
+
+	keys := func(recs []interfaces.Relatable) []string {
+		var keys []string
+		for _, r := range recs {
+			keys = append(keys, fmt.Sprintf("%s:%d:%s", r.Chrom(), r.Start()+1, r.(interfaces.IRefAlt).Alt()[0]))
+		}
+		return keys
+	}
+	onlyLeft, onlyRight, err := left.Diff(right, interfaces.AsIPosition("1", 0, 1000))
+	c.Assert(err, IsNil)
+	c.Check(keys(onlyLeft), DeepEquals, []string{"1:200:T"})
+	c.Check(keys(onlyRight), DeepEquals, []string{"chr1:250:C"})
+
+	onlyLeft, onlyRight, err = left.Diff(right, nil)
+	c.Assert(err, IsNil)
+	c.Check(keys(onlyLeft), DeepEquals, []string{"1:200:T", "2:10:G"})
+	c.Check(keys(onlyRight), DeepEquals, []string{"chr1:250:C", "chr3:5:A"})
+
+	onlyLeft, onlyRight, err = left.Diff(left, nil)
+	c.Assert(err, IsNil)
+	c.Check(onlyLeft, HasLen, 0)
+	c.Check(onlyRight, HasLen, 0)
+}
+
+func (s *BixSuite) TestDiffIntervals(c *C) {
+	left, err := New(writeTabix(c, "left.bed.gz", bedIndex(), []string{"chr1\t10\t20", "chr1\t10\t20", "chr1\t30\t40"}))
+	c.Assert(err, IsNil)
+	defer left.Close()
+	right, err := New(writeTabix(c, "right.bed.gz", bedIndex(), []string{"chr1\t10\t20", "chr1\t30\t45"}))
+	c.Assert(err, IsNil)
+	defer right.Close()
+	left.SetReuseBuffers(true)
+
+	onlyLeft, onlyRight, err := left.Diff(right, nil)
+	c.Assert(err, IsNil)
+	c.Assert(onlyLeft, HasLen, 2)
+	c.Check(onlyLeft[0].End(), Equals, uint32(20))
+	c.Check(onlyLeft[1].End(), Equals, uint32(40))
+	c.Assert(onlyRight, HasLen, 1)
+	c.Check(onlyRight[0].End(), Equals, uint32(45))
+}
@@ -0,0 +1,55 @@
+package bix
+
+import (
+	"crypto/sha256"
+	"encoding/binary"
+	"encoding/hex"
+	"hash"
+	"io"
+	"os"
+
+	"github.com/pkg/errors"
+)
+
+// Fingerprint returns a hex string that identifies the current contents of
+// the file, for use in the keys of a cache of query results. It is a hash of
+// the index file and of the size and modification time of the data file, so
+// it changes when either file is rewritten. For a Bix from NewMulti, it
+// covers all of the files.
+func (tbx *Bix) Fingerprint() (string, error) {
+	h := sha256.New()
+	if tbx.multi == nil {
+		if err := tbx.fingerprint(h); err != nil {
+			return "", err
+		}
+	}
+	for _, b := range tbx.multi {
+		if err := b.fingerprint(h); err != nil {
+			return "", err
+		}
+	}
+	return hex.EncodeToString(h.Sum(nil)), nil
+}
+
+// fingerprint writes the identity of tbx's files to h.
+func (tbx *Bix) fingerprint(h hash.Hash) error {
+	if isURL(tbx.path) {
+		return errors.Errorf("bix: fingerprint not supported for %s, which is not a local file", tbx.path)
+	}
+	if tbx.indexPath == "" {
+		return errors.Errorf("bix: fingerprint not supported for %s, whose index was not read from a file", tbx.path)
+	}
+	f, err := os.Open(tbx.indexPath)
+	if err != nil {
+		return errors.Wrapf(err, "bix: error opening index %s", tbx.indexPath)
+	}
+	defer f.Close()
+	if _, err := io.Copy(h, f); err != nil {
+		return errors.Wrapf(err, "bix: error reading index %s", tbx.indexPath)
+	}
+	fi, err := os.Stat(tbx.path)
+	if err != nil {
+		return errors.Wrapf(err, "bix: error getting file info for %s", tbx.path)
+	}
+	return binary.Write(h, binary.LittleEndian, [2]int64{fi.Size(), fi.ModTime().UnixNano()})
+}
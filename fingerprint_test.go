@@ -0,0 +1,48 @@
+package bix
+
+import (
+	"os"
+	"time"
+
+	. "gopkg.in/check.v1"
+)
+
+func (s *BixSuite) TestFingerprint(c *C) {
+	path := writeTabix(c, "f.bed.gz", bedIndex(), scanLines(10))
+	tbx, err := New(path)
+	c.Assert(err, IsNil)
+	defer tbx.Close()
+
+	a, err := tbx.Fingerprint()
+	c.Assert(err, IsNil)
+	c.Check(a, HasLen, 64)
+	b, err := tbx.Fingerprint()
+	c.Assert(err, IsNil)
+	c.Check(b, Equals, a)
+
+	clone, err := tbx.Clone()
+	c.Assert(err, IsNil)
+	defer clone.Close()
+	b, err = clone.Fingerprint()
+	c.Assert(err, IsNil)
+	c.Check(b, Equals, a)
+
+	// the same index with different data.
+	other := writeTabix(c, "f.bed.gz", bedIndex(), scanLines(11))
+	tbx2, err := NewWithIndex(other, path+".tbi", 1)
+	c.Assert(err, IsNil)
+	defer tbx2.Close()
+	b, err = tbx2.Fingerprint()
+	c.Assert(err, IsNil)
+	c.Check(b, Not(Equals), a)
+
+	// a change to only the modification time.
+	c.Assert(os.Chtimes(path, time.Now(), time.Now().Add(time.Hour)), IsNil)
+	b, err = tbx.Fingerprint()
+	c.Assert(err, IsNil)
+	c.Check(b, Not(Equals), a)
+
+	c.Assert(os.Remove(path+".tbi"), IsNil)
+	_, err = tbx.Fingerprint()
+	c.Check(err, NotNil)
+}
@@ -0,0 +1,197 @@
+package bix
+
+import (
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"strconv"
+	"strings"
+
+	"github.com/pkg/errors"
+)
+
+// dataFile is the data file of a Bix, either local or read over HTTP.
+type dataFile interface {
+	io.ReadSeeker
+	io.ReaderAt
+	io.Closer
+}
+
+// isURL returns true if path is an HTTP or HTTPS URL.
+func isURL(path string) bool {
+	return strings.HasPrefix(path, "http://") || strings.HasPrefix(path, "https://")
+}
+
+// openData opens the data file at path, which may be a URL.
+func openData(path string) (dataFile, error) {
+	if isURL(path) {
+		return openHTTP(path)
+	}
+	return os.Open(path)
+}
+
+// dataSize returns the size in bytes of f.
+func dataSize(f dataFile) (int64, error) {
+	switch f := f.(type) {
+	case *os.File:
+		fi, err := f.Stat()
+		if err != nil {
+			return 0, err
+		}
+		return fi.Size(), nil
+	case *httpFile:
+		return f.size, nil
+	}
+	return 0, errors.Errorf("bix: unknown data file type %T", f)
+}
+
+// NewHTTP returns a &Bix for the file at url, which must be served by a
+// server that supports HTTP Range requests. The index, url + ".csi" or else
+// url + ".tbi", is fetched in full, but the data file is read with Range
+// requests starting at the chunks that the index gives for each query, so
+// only the parts of the file holding the region are transferred. Each query
+// makes its own requests. Fingerprint, which needs local files, is not
+// supported.
+func NewHTTP(url string, workers int) (*Bix, error) {
+	for _, ext := range []string{".csi", ".tbi"} {
+		resp, err := http.Get(url + ext)
+		if err != nil {
+			return nil, errors.Wrapf(err, "bix: error requesting %s", url+ext)
+		}
+		if resp.StatusCode == http.StatusNotFound {
+			resp.Body.Close()
+			continue
+		}
+		if resp.StatusCode != http.StatusOK {
+			resp.Body.Close()
+			return nil, errors.Errorf("bix: error requesting %s: %s", url+ext, resp.Status)
+		}
+		idx, err := readIndexFrom(resp.Body, url+ext)
+		resp.Body.Close()
+		if err != nil {
+			return nil, err
+		}
+		return newWithIndex(url, url+ext, idx, workers, nil)
+	}
+	return nil, errors.Errorf("bix: no .tbi or .csi index found for %s (tried %s.tbi and %s.csi)", url, url, url)
+}
+
+// httpFile reads a file over HTTP with Range requests. Reads after a Seek
+// stream the file from the new offset in a single request, which is closed
+// by the next Seek to another offset.
+type httpFile struct {
+	url  string
+	size int64
+	off  int64
+	// the response for the reads from off, or nil before the first read.
+	body io.ReadCloser
+}
+
+func openHTTP(url string) (*httpFile, error) {
+	// a request for the first byte checks that ranges are supported and gives
+	// the size of the file.
+	resp, err := rangeRequest(url, 0, 1)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+	cr := resp.Header.Get("Content-Range")
+	size, err := strconv.ParseInt(cr[strings.LastIndexByte(cr, '/')+1:], 10, 64)
+	if err != nil {
+		return nil, errors.Errorf("bix: unknown size of %s from Content-Range %q", url, cr)
+	}
+	return &httpFile{url: url, size: size}, nil
+}
+
+// rangeRequest requests n bytes of url from off, or the rest of the file if n
+// is 0.
+func rangeRequest(url string, off, n int64) (*http.Response, error) {
+	req, err := http.NewRequest("GET", url, nil)
+	if err != nil {
+		return nil, errors.Wrapf(err, "bix: error creating request for %s", url)
+	}
+	if n > 0 {
+		req.Header.Set("Range", fmt.Sprintf("bytes=%d-%d", off, off+n-1))
+	} else {
+		req.Header.Set("Range", fmt.Sprintf("bytes=%d-", off))
+	}
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		return nil, errors.Wrapf(err, "bix: error requesting %s", url)
+	}
+	if resp.StatusCode != http.StatusPartialContent {
+		resp.Body.Close()
+		return nil, errors.Errorf("bix: range request for %s returned %s; the server must support Range requests", url, resp.Status)
+	}
+	return resp, nil
+}
+
+func (f *httpFile) Read(p []byte) (int, error) {
+	if f.off >= f.size {
+		return 0, io.EOF
+	}
+	if f.body == nil {
+		resp, err := rangeRequest(f.url, f.off, 0)
+		if err != nil {
+			return 0, err
+		}
+		f.body = resp.Body
+	}
+	n, err := f.body.Read(p)
+	f.off += int64(n)
+	if err == io.EOF && f.off < f.size {
+		err = io.ErrUnexpectedEOF
+	}
+	return n, err
+}
+
+func (f *httpFile) Seek(offset int64, whence int) (int64, error) {
+	switch whence {
+	case io.SeekCurrent:
+		offset += f.off
+	case io.SeekEnd:
+		offset += f.size
+	}
+	if offset < 0 {
+		return 0, errors.Errorf("bix: seek to negative offset %d in %s", offset, f.url)
+	}
+	if offset != f.off && f.body != nil {
+		f.body.Close()
+		f.body = nil
+	}
+	f.off = offset
+	return offset, nil
+}
+
+func (f *httpFile) ReadAt(p []byte, off int64) (int, error) {
+	if off >= f.size {
+		return 0, io.EOF
+	}
+	n := int64(len(p))
+	if off+n > f.size {
+		n = f.size - off
+	}
+	if n == 0 {
+		return 0, nil
+	}
+	resp, err := rangeRequest(f.url, off, n)
+	if err != nil {
+		return 0, err
+	}
+	defer resp.Body.Close()
+	m, err := io.ReadFull(resp.Body, p[:n])
+	if err == nil && m < len(p) {
+		err = io.EOF
+	}
+	return m, err
+}
+
+func (f *httpFile) Close() error {
+	if f.body == nil {
+		return nil
+	}
+	err := f.body.Close()
+	f.body = nil
+	return err
+}
@@ -0,0 +1,82 @@
+package bix
+
+import (
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"strings"
+	"sync"
+
+	"github.com/brentp/irelate/interfaces"
+	. "gopkg.in/check.v1"
+)
+
+func (s *BixSuite) TestNewHTTP(c *C) {
+	var mu sync.Mutex
+	var ranges []string
+	files := http.FileServer(http.Dir("main"))
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if strings.HasSuffix(r.URL.Path, ".gz") {
+			mu.Lock()
+			ranges = append(ranges, r.Header.Get("Range"))
+			mu.Unlock()
+		}
+		files.ServeHTTP(w, r)
+	}))
+	defer srv.Close()
+
+	local, err := New("main/NA12878.wham.del.vcf.gz")
+	c.Assert(err, IsNil)
+	defer local.Close()
+	remote, err := NewHTTP(srv.URL+"/NA12878.wham.del.vcf.gz", 1)
+	c.Assert(err, IsNil)
+	defer remote.Close()
+	c.Check(remote.IsVCF(), Equals, true)
+	c.Check(remote.Names(), DeepEquals, local.Names())
+
+	starts := func(tbx *Bix, region interfaces.IPosition) []uint32 {
+		it, err := tbx.Query(region)
+		c.Assert(err, IsNil)
+		var starts []uint32
+		for _, r := range collect(c, it) {
+			starts = append(starts, r.Start())
+		}
+		return starts
+	}
+	for _, region := range []interfaces.IPosition{
+		interfaces.AsIPosition("1", 755600, 755700),
+		interfaces.AsIPosition("1", 0, maxEnd),
+		nil,
+	} {
+		want := starts(local, region)
+		c.Check(want, Not(HasLen), 0)
+		c.Check(starts(remote, region), DeepEquals, want)
+	}
+
+	// every read of the data file is a range request.
+	for _, r := range ranges {
+		c.Check(r, Matches, "bytes=\\d+-\\d*")
+	}
+
+	_, err = remote.Fingerprint()
+	c.Check(err, ErrorMatches, "bix: fingerprint not supported .*")
+	_, err = NewHTTP(srv.URL+"/missing.vcf.gz", 1)
+	c.Check(err, ErrorMatches, "bix: no .tbi or .csi index found for .*")
+}
+
+func (s *BixSuite) TestNewHTTPNoRanges(c *C) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		f, err := os.Open("main" + r.URL.Path)
+		if err != nil {
+			http.NotFound(w, r)
+			return
+		}
+		defer f.Close()
+		io.Copy(w, f)
+	}))
+	defer srv.Close()
+
+	_, err := NewHTTP(srv.URL+"/NA12878.wham.del.vcf.gz", 1)
+	c.Check(err, ErrorMatches, "bix: range request for .* returned 200 OK; the server must support Range requests")
+}
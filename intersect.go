@@ -0,0 +1,216 @@
+package bix
+
+import (
+	"bufio"
+	"compress/gzip"
+	"io"
+	"os"
+	"strconv"
+	"strings"
+
+	"github.com/brentp/irelate/interfaces"
+	"github.com/pkg/errors"
+)
+
+// intersectGap is the largest gap between query regions that are read with a
+// single query. It is the width of a tile of the linear index, so regions in
+// the same tile share the reads of its blocks.
+const intersectGap = tileWidth
+
+// Intersect returns the records that overlap any of the regions in the BED
+// file at queryBEDPath, which may be gzipped, as with bedtools intersect -u.
+// The regions must be sorted by start within each contig and the lines for a
+// contig must be together. They are read as the iterator advances, and
+// regions near one another are read with a single query, so this is much
+// faster than a Query for each region. Each record is returned once, in the
+// order of the file within each contig, however many regions it overlaps.
+func (tbx *Bix) Intersect(queryBEDPath string) (interfaces.RelatableIterator, error) {
+	f, err := os.Open(queryBEDPath)
+	if err != nil {
+		return nil, errors.Wrapf(err, "bix: error opening %s", queryBEDPath)
+	}
+	it := &intersectIterator{tbx: tbx, path: queryBEDPath, f: f, seen: make(map[string]bool)}
+	br := bufio.NewReader(f)
+	if magic, err := br.Peek(2); err == nil && magic[0] == 0x1f && magic[1] == 0x8b {
+		gz, err := gzip.NewReader(br)
+		if err != nil {
+			f.Close()
+			return nil, errors.Wrapf(err, "bix: error reading %s", queryBEDPath)
+		}
+		it.gz = gz
+		br = bufio.NewReader(gz)
+	}
+	it.r = br
+	return it, nil
+}
+
+// intersectIterator sweeps the regions of a BED file, querying a cluster of
+// nearby regions at a time and returning the records that overlap them.
+type intersectIterator struct {
+	tbx  *Bix
+	path string
+	f    *os.File
+	gz   *gzip.Reader
+	r    *bufio.Reader
+	line int
+
+	// the next region, which is not in the current cluster, if read.
+	next *Interval
+	// the contigs of earlier clusters, to check that the regions are sorted.
+	seen map[string]bool
+
+	// the query for the current cluster and its regions, merged and adjusted
+	// to the bounds used by tbx, in which records are found with the index j.
+	bx    *bixerator
+	chrom string
+	union []Interval
+	j     int
+	// records that start before skip were returned for the last cluster.
+	skip uint32
+}
+
+func (it *intersectIterator) Next() (interfaces.Relatable, error) {
+	for {
+		if it.bx == nil {
+			if err := it.nextCluster(); err != nil {
+				return nil, err
+			}
+			continue
+		}
+		v, err := it.bx.Next()
+		if err == io.EOF {
+			it.bx.Close()
+			it.bx = nil
+			continue
+		} else if err != nil {
+			return nil, err
+		}
+		if it.overlaps(v) {
+			return v, nil
+		}
+	}
+}
+
+// overlaps returns true if v, which starts at or after the previous record,
+// overlaps a region of the cluster and was not returned for the last one.
+func (it *intersectIterator) overlaps(v interfaces.Relatable) bool {
+	s, e := v.Start(), v.End()
+	if s < it.skip {
+		return false
+	}
+	// a zero-length record is a point at its start.
+	if e <= s {
+		e = s + 1
+	}
+	for it.j < len(it.union) && it.union[it.j].End <= s {
+		it.j++
+	}
+	return it.j < len(it.union) && e > it.union[it.j].Start
+}
+
+// nextCluster queries the next regions of the BED file that are within
+// intersectGap of one another. It returns io.EOF when there are none.
+func (it *intersectIterator) nextCluster() error {
+	first, err := it.region()
+	if err != nil {
+		return err
+	}
+	if first.Chrom == it.chrom {
+		it.skip = it.union[len(it.union)-1].End
+	} else {
+		if it.seen[first.Chrom] {
+			return errors.Errorf("bix: regions for %s are not together in %s at line %d", first.Chrom, it.path, it.line)
+		}
+		it.seen[first.Chrom] = true
+		it.chrom, it.skip = first.Chrom, 0
+	}
+	start := first.Start
+	it.union, it.j = append(it.union[:0], it.bounds(first)), 0
+	for {
+		r, err := it.region()
+		if err == io.EOF {
+			break
+		} else if err != nil {
+			return err
+		}
+		last := &it.union[len(it.union)-1]
+		b := it.bounds(r)
+		if r.Chrom != it.chrom || b.Start > last.End+intersectGap {
+			it.next = &r
+			break
+		}
+		if b.Start <= last.End {
+			if b.End > last.End {
+				last.End = b.End
+			}
+		} else {
+			it.union = append(it.union, b)
+		}
+	}
+	bx, err := it.tbx.queryBixerator(interfaces.AsIPosition(it.chrom, int(start), int(it.union[len(it.union)-1].End)))
+	if err != nil {
+		return err
+	}
+	it.bx = bx
+	return nil
+}
+
+// bounds returns r with the bounds that records must overlap to be in it,
+// as for a Query of r.
+func (it *intersectIterator) bounds(r Interval) Interval {
+	p := interfaces.AsIPosition(r.Chrom, int(r.Start), int(r.End))
+	return Interval{Chrom: r.Chrom, Start: uint32(it.tbx.regionStart(p)), End: uint32(it.tbx.regionEnd(p))}
+}
+
+// region returns the next region of the BED file, checking that it does not
+// start before the previous one on its contig.
+func (it *intersectIterator) region() (Interval, error) {
+	if it.next != nil {
+		r := *it.next
+		it.next = nil
+		return r, nil
+	}
+	for {
+		line, err := it.r.ReadString('\n')
+		if err == io.EOF && len(line) == 0 {
+			return Interval{}, io.EOF
+		} else if err != nil && err != io.EOF {
+			return Interval{}, errors.Wrapf(err, "bix: error reading %s", it.path)
+		}
+		it.line++
+		toks := strings.Fields(line)
+		if len(toks) == 0 || toks[0][0] == '#' || toks[0] == "track" || toks[0] == "browser" {
+			continue
+		}
+		if len(toks) < 3 {
+			return Interval{}, errors.Errorf("bix: expected at least 3 columns at line %d of %s", it.line, it.path)
+		}
+		s, err := strconv.Atoi(toks[1])
+		if err != nil {
+			return Interval{}, errors.Wrapf(err, "bix: invalid start at line %d of %s", it.line, it.path)
+		}
+		e, err := strconv.Atoi(toks[2])
+		if err != nil {
+			return Interval{}, errors.Wrapf(err, "bix: invalid end at line %d of %s", it.line, it.path)
+		}
+		if s < 0 || e < s || e > maxEnd {
+			return Interval{}, errors.Errorf("bix: invalid region %s:%d-%d at line %d of %s", toks[0], s, e, it.line, it.path)
+		}
+		r := Interval{Chrom: toks[0], Start: uint32(s), End: uint32(e)}
+		if r.Chrom == it.chrom && len(it.union) > 0 && it.bounds(r).Start < it.union[len(it.union)-1].Start {
+			return Interval{}, errors.Errorf("bix: regions are not sorted at line %d of %s", it.line, it.path)
+		}
+		return r, nil
+	}
+}
+
+func (it *intersectIterator) Close() error {
+	if it.bx != nil {
+		it.bx.Close()
+		it.bx = nil
+	}
+	if it.gz != nil {
+		it.gz.Close()
+	}
+	return it.f.Close()
+}
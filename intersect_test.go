@@ -0,0 +1,101 @@
+package bix
+
+import (
+	"bytes"
+	"compress/gzip"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+
+	"github.com/brentp/irelate/interfaces"
+	. "gopkg.in/check.v1"
+)
+
+func (s *BixSuite) TestIntersect(c *C) {
+	var lines []string
+	for _, chrom := range []string{"chr1", "chr2"} {
+		for i := 0; i < 2000; i++ {
+			// the records do not cross tiles of the index.
+			lines = append(lines, fmt.Sprintf("%s\t%d\t%d\t%s-%d", chrom, i*128, i*128+100, chrom, i))
+		}
+	}
+	tbx, err := New(writeTabix(c, "target.bed.gz", bedIndex(), lines))
+	c.Assert(err, IsNil)
+	defer tbx.Close()
+
+	queries := []string{
+		"track name=q",
+		"# a comment",
+		"chr1\t120\t130",
+		"chr1\t125\t260",
+		"chr1\t50000\t50001",
+		"chr1\t50020\t50030",
+		"chr1\t150000\t150100",
+		"chr3\t0\t1000",
+		"chr2\t0\t10",
+		"chr2\t199900\t300000",
+	}
+	dir := c.MkDir()
+	path := filepath.Join(dir, "q.bed")
+	c.Assert(os.WriteFile(path, []byte(strings.Join(queries, "\n")+"\n"), 0644), IsNil)
+	var gz bytes.Buffer
+	w := gzip.NewWriter(&gz)
+	w.Write([]byte(strings.Join(queries, "\n")))
+	c.Assert(w.Close(), IsNil)
+	c.Assert(os.WriteFile(path+".gz", gz.Bytes(), 0644), IsNil)
+
+	names := func(it interfaces.RelatableIterator) []string {
+		var names []string
+		for _, r := range collect(c, it) {
+			names = append(names, string(recordFields(r)[3]))
+		}
+		return names
+	}
+	// the records of a Query for each region, without repeats.
+	var want []string
+	seen := make(map[string]bool)
+	for _, q := range queries[2:] {
+		f := strings.Fields(q)
+		start, _ := strconv.Atoi(f[1])
+		end, _ := strconv.Atoi(f[2])
+		it, err := tbx.Query(interfaces.AsIPosition(f[0], start, end))
+		c.Assert(err, IsNil)
+		for _, n := range names(it) {
+			if !seen[n] {
+				seen[n] = true
+				want = append(want, n)
+			}
+		}
+	}
+	c.Assert(want, Not(HasLen), 0)
+
+	for _, p := range []string{path, path + ".gz"} {
+		it, err := tbx.Intersect(p)
+		c.Assert(err, IsNil)
+		c.Check(names(it), DeepEquals, want, Commentf(p))
+	}
+
+	for _, t := range []struct {
+		queries []string
+		err     string
+	}{
+		{[]string{"chr1\t500\t600", "chr1\t100\t200"}, "bix: regions are not sorted at line 2 of .*"},
+		{[]string{"chr1\t0\t10", "chr2\t0\t10", "chr1\t50000\t50010"}, "bix: regions for chr1 are not together .*"},
+		{[]string{"chr1\t10"}, "bix: expected at least 3 columns at line 1 of .*"},
+		{[]string{"chr1\t20\t10"}, "bix: invalid region chr1:20-10 .*"},
+	} {
+		p := filepath.Join(dir, "bad.bed")
+		c.Assert(os.WriteFile(p, []byte(strings.Join(t.queries, "\n")), 0644), IsNil)
+		it, err := tbx.Intersect(p)
+		c.Assert(err, IsNil)
+		for err == nil {
+			_, err = it.Next()
+		}
+		c.Check(err, ErrorMatches, t.err)
+		c.Check(it.Close(), IsNil)
+	}
+	_, err = tbx.Intersect(filepath.Join(dir, "missing.bed"))
+	c.Check(err, NotNil)
+}
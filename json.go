@@ -0,0 +1,98 @@
+package bix
+
+import (
+	"bytes"
+	"encoding/json"
+
+	"github.com/brentp/irelate/interfaces"
+	"github.com/pkg/errors"
+)
+
+// variantJSON is the JSON form of a VCF record.
+type variantJSON struct {
+	Chrom   string                       `json:"chrom"`
+	Start   uint32                       `json:"start"`
+	End     uint32                       `json:"end"`
+	ID      string                       `json:"id"`
+	Ref     string                       `json:"ref"`
+	Alt     []string                     `json:"alt"`
+	Qual    float32                      `json:"qual"`
+	Filter  string                       `json:"filter"`
+	Info    map[string]interface{}       `json:"info"`
+	Samples map[string]map[string]string `json:"samples,omitempty"`
+}
+
+// MarshalRecord returns r, a record from tbx, as a JSON object with 0-based,
+// half-open "chrom", "start" and "end". For VCF, the object also has "id",
+// "ref", "alt", "qual", "filter", an "info" object and a "samples" object
+// with the FORMAT fields of each sample. For other files, the columns are
+// keyed by their ColumnNames, from the header line chosen as described for
+// SetHeaderLine, if it has a name for each column, leaving out the chrom,
+// start and end columns, and are otherwise given as a "fields" array of
+// strings.
+func (tbx *Bix) MarshalRecord(r interfaces.Relatable) ([]byte, error) {
+	if v := asVariant(r); v != nil {
+		o := variantJSON{Chrom: r.Chrom(), Start: r.Start(), End: r.End(), ID: v.Id(), Ref: v.Ref(),
+			Alt: v.Alt(), Qual: v.Quality, Filter: v.Filter, Info: make(map[string]interface{})}
+		for _, k := range v.Info().Keys() {
+			val, err := v.Info().Get(k)
+			if err != nil {
+				return nil, errors.Wrapf(err, "bix: error getting INFO %s", k)
+			}
+			o.Info[k] = val
+		}
+		if v.Header != nil {
+			// vcfgo reports an error for valid but sparse samples such as
+			// "./." and still parses them, so errors are ignored.
+			v.Header.ParseSamples(v)
+			for i, s := range v.Samples {
+				if o.Samples == nil {
+					o.Samples = make(map[string]map[string]string)
+				}
+				if i < len(v.Header.SampleNames) && s != nil {
+					o.Samples[v.Header.SampleNames[i]] = s.Fields
+				}
+			}
+		}
+		return json.Marshal(o)
+	}
+
+	fields := recordFields(r)
+	var buf bytes.Buffer
+	buf.WriteString(`{"chrom":`)
+	writeJSON(&buf, r.Chrom())
+	buf.WriteString(`,"start":`)
+	writeJSON(&buf, r.Start())
+	buf.WriteString(`,"end":`)
+	writeJSON(&buf, r.End())
+	if len(tbx.columns) == len(fields) {
+		for i, f := range fields {
+			if i == tbx.NameColumn()-1 || i == tbx.BeginColumn()-1 || i == tbx.EndColumn()-1 {
+				// already given as chrom, start and end.
+				continue
+			}
+			buf.WriteByte(',')
+			writeJSON(&buf, tbx.columns[i])
+			buf.WriteByte(':')
+			writeJSON(&buf, string(f))
+		}
+	} else {
+		buf.WriteString(`,"fields":[`)
+		for i, f := range fields {
+			if i > 0 {
+				buf.WriteByte(',')
+			}
+			writeJSON(&buf, string(f))
+		}
+		buf.WriteByte(']')
+	}
+	buf.WriteByte('}')
+	return buf.Bytes(), nil
+}
+
+// writeJSON writes v, a string or number, to buf as JSON.
+func writeJSON(buf *bytes.Buffer, v interface{}) {
+	// strings and numbers always marshal.
+	b, _ := json.Marshal(v)
+	buf.Write(b)
+}
@@ -0,0 +1,59 @@
+package bix
+
+import (
+	"encoding/json"
+
+	"github.com/brentp/irelate/interfaces"
+	. "gopkg.in/check.v1"
+)
+
+func (s *BixSuite) TestMarshalRecord(c *C) {
+	tbx, err := New(writeTabix(c, "json.bed.gz", bedIndex(), []string{
+		"#chrom\tstart\tend\tname\tscore",
+		"chr1\t10\t20\tgeneA\t5",
+	}))
+	c.Assert(err, IsNil)
+	defer tbx.Close()
+	it, err := tbx.Query(nil)
+	c.Assert(err, IsNil)
+	recs := collect(c, it)
+	c.Assert(recs, HasLen, 1)
+	b, err := tbx.MarshalRecord(recs[0])
+	c.Assert(err, IsNil)
+	c.Check(string(b), Equals, `{"chrom":"chr1","start":10,"end":20,"name":"geneA","score":"5"}`)
+
+	tbx, err = New(writeTabix(c, "json.bed.gz", bedIndex(), []string{"chr2\t1\t5\t\"x\""}))
+	c.Assert(err, IsNil)
+	defer tbx.Close()
+	it, err = tbx.Query(interfaces.AsIPosition("chr2", 0, 10))
+	c.Assert(err, IsNil)
+	recs = collect(c, it)
+	c.Assert(recs, HasLen, 1)
+	b, err = tbx.MarshalRecord(recs[0])
+	c.Assert(err, IsNil)
+	c.Check(string(b), Equals, `{"chrom":"chr2","start":1,"end":5,"fields":["chr2","1","5","\"x\""]}`)
+}
+
+func (s *BixSuite) TestMarshalVariant(c *C) {
+	tbx, err := New(chromVCF(c, "chr1", "S1", nil, 100))
+	c.Assert(err, IsNil)
+	defer tbx.Close()
+	it, err := tbx.Query(nil)
+	c.Assert(err, IsNil)
+	recs := collect(c, it)
+	c.Assert(recs, HasLen, 1)
+	b, err := tbx.MarshalRecord(recs[0])
+	c.Assert(err, IsNil)
+
+	var v map[string]interface{}
+	c.Assert(json.Unmarshal(b, &v), IsNil)
+	c.Check(v["chrom"], Equals, "chr1")
+	c.Check(v["start"], Equals, 99.0)
+	c.Check(v["end"], Equals, 100.0)
+	c.Check(v["ref"], Equals, "A")
+	c.Check(v["alt"], DeepEquals, []interface{}{"T"})
+	c.Check(v["qual"], Equals, 50.0)
+	c.Check(v["filter"], Equals, "PASS")
+	c.Check(v["info"], DeepEquals, map[string]interface{}{"DP": 100.0})
+	c.Check(v["samples"], DeepEquals, map[string]interface{}{"S1": map[string]interface{}{"GT": "0/1"}})
+}
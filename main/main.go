@@ -1,13 +1,17 @@
 package main
 
 import (
+	"bufio"
+	"flag"
 	"fmt"
+	"io"
 	"log"
 	"os"
-	"strconv"
+	"strings"
 
 	"github.com/brentp/bix"
 	"github.com/brentp/irelate/interfaces"
+	"github.com/pkg/errors"
 )
 
 func check(e error) {
@@ -16,53 +20,87 @@ func check(e error) {
 	}
 }
 
-func min(a, b int) int {
-	if a < b {
-		return a
-	}
-	return b
-}
-
-type loc struct {
-	chrom string
-	start int
-	end   int
-}
-
-func (s loc) Chrom() string {
-	return s.chrom
-}
-func (s loc) Start() uint32 {
-	return uint32(s.start)
-}
-func (s loc) End() uint32 {
-	return uint32(s.end)
-}
-
 func main() {
+	region := flag.String("region", "", "region to extract, e.g. chr1:100-200 (1-based, inclusive); the whole file if empty")
+	format := flag.String("format", "raw", "output format: raw for the original lines or json for one object per line")
+	header := flag.Bool("header", false, "print the VCF header before the records (raw format only)")
+	flag.Usage = func() {
+		fmt.Fprintf(os.Stderr, "usage: %s [flags] file.gz\n", os.Args[0])
+		flag.PrintDefaults()
+	}
+	flag.Parse()
+	if flag.NArg() != 1 || (*format != "raw" && *format != "json") {
+		flag.Usage()
+		os.Exit(2)
+	}
 
-	f := os.Args[1]
-	tbx, err := bix.New(f)
+	tbx, err := bix.New(flag.Arg(0))
 	check(err)
+	defer tbx.Close()
+	tbx.SetStrict(true)
 
-	chrom := os.Args[2]
+	var pos interfaces.IPosition
+	if *region != "" {
+		pos, err = bix.ParseRegion(*region)
+		check(err)
+	}
 
-	s, err := strconv.Atoi(os.Args[3])
+	w := bufio.NewWriter(os.Stdout)
+	if *format == "json" {
+		err = writeJSON(w, tbx, pos)
+	} else {
+		if *header {
+			check(tbx.WriteVCFHeader(w))
+		}
+		err = writeRaw(w, tbx, pos)
+	}
+	if errors.Cause(err) == bix.ErrUnknownContig {
+		w.Flush()
+		fmt.Fprintf(os.Stderr, "unknown contig %s in %s. contigs are: %s\n", pos.Chrom(), flag.Arg(0), strings.Join(tbx.Names(), ", "))
+		os.Exit(1)
+	}
 	check(err)
+	check(w.Flush())
+}
 
-	e, err := strconv.Atoi(os.Args[4])
-	check(err)
+func writeRaw(w io.Writer, tbx *bix.Bix, region interfaces.IPosition) error {
+	it, err := tbx.QueryRaw(region)
+	if err != nil {
+		return err
+	}
+	defer it.Close()
+	for {
+		line, err := it.Next()
+		if err == io.EOF {
+			return nil
+		} else if err != nil {
+			return err
+		}
+		if _, err := fmt.Fprintf(w, "%s\n", line); err != nil {
+			return err
+		}
+	}
+}
 
-	vals, _ := tbx.Query(loc{chrom, s, e})
-	i := 0
+func writeJSON(w io.Writer, tbx *bix.Bix, region interfaces.IPosition) error {
+	it, err := tbx.Query(region)
+	if err != nil {
+		return err
+	}
+	defer it.Close()
 	for {
-		v, err := vals.Next()
+		v, err := it.Next()
+		if err == io.EOF {
+			return nil
+		} else if err != nil {
+			return err
+		}
+		b, err := tbx.MarshalRecord(v)
 		if err != nil {
-			break
+			return err
+		}
+		if _, err := fmt.Fprintf(w, "%s\n", b); err != nil {
+			return err
 		}
-		fmt.Println(v.(interfaces.IVariant).String()[:40])
-		i++
 	}
-	tbx.Close()
-
 }
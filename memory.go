@@ -0,0 +1,143 @@
+package bix
+
+import (
+	"io"
+	"sort"
+
+	"github.com/brentp/irelate/interfaces"
+)
+
+// MemBix holds every record from a file in memory, in an interval tree for
+// each contig, for fast, repeated queries. It is meant for small files, such as annotation tracks, that are
+// queried many times: it uses memory proportional to the size of the
+// uncompressed file (and more, since each record is parsed), but a query
+// needs no I/O.
+type MemBix struct {
+	names   []string
+	contigs map[string]*memContig
+	// from the Bix.
+	inclusiveEnd bool
+	bookended    bool
+	maxSpan      int
+}
+
+// memContig holds the records for a contig sorted by start as an implicit
+// interval tree: the root of the records in [lo, hi) is the middle one, and
+// maxEnd holds the greatest end of the records under each root, so subtrees
+// that end before a region are skipped. A query takes O(log n + k) for k
+// results, however long the records are.
+type memContig struct {
+	recs   []interfaces.Relatable
+	maxEnd []uint32
+}
+
+// index sets maxEnd for the subtree of the records in [lo, hi) and returns
+// its greatest end.
+func (c *memContig) index(lo, hi int) uint32 {
+	if lo >= hi {
+		return 0
+	}
+	mid := (lo + hi) / 2
+	e := c.recs[mid].End()
+	if l := c.index(lo, mid); l > e {
+		e = l
+	}
+	if r := c.index(mid+1, hi); r > e {
+		e = r
+	}
+	c.maxEnd[mid] = e
+	return e
+}
+
+// search calls fn, in order of start, for the records in [lo, hi) that may
+// overlap [start, end): those that end at or after start and start before
+// end.
+func (c *memContig) search(lo, hi int, start, end uint32, fn func(interfaces.Relatable)) {
+	if lo >= hi {
+		return
+	}
+	mid := (lo + hi) / 2
+	if c.maxEnd[mid] < start {
+		return
+	}
+	c.search(lo, mid, start, end, fn)
+	r := c.recs[mid]
+	if r.Start() >= end {
+		return
+	}
+	if r.End() >= start {
+		fn(r)
+	}
+	c.search(mid+1, hi, start, end, fn)
+}
+
+// LoadMemory reads all records into memory. See MemBix.
+func (tbx *Bix) LoadMemory() (*MemBix, error) {
+	bx, err := tbx.queryBixerator(nil)
+	if err != nil {
+		return nil, err
+	}
+	defer bx.Close()
+	// the records are kept, so they must not share buffers.
+	bx.keep = true
+
+	m := &MemBix{contigs: make(map[string]*memContig), inclusiveEnd: tbx.inclusiveEnd, bookended: tbx.bookended, maxSpan: tbx.maxSpan}
+	for {
+		v, err := bx.Next()
+		if err == io.EOF {
+			break
+		} else if err != nil {
+			return nil, err
+		}
+		c, ok := m.contigs[v.Chrom()]
+		if !ok {
+			c = &memContig{}
+			m.contigs[v.Chrom()] = c
+			m.names = append(m.names, v.Chrom())
+		}
+		c.recs = append(c.recs, v)
+	}
+	for _, c := range m.contigs {
+		sort.SliceStable(c.recs, func(i, j int) bool { return c.recs[i].Start() < c.recs[j].Start() })
+		c.maxEnd = make([]uint32, len(c.recs))
+		c.index(0, len(c.recs))
+	}
+	return m, nil
+}
+
+// Query returns an iterator over the records that overlap region, in order
+// of start. A record overlaps if it starts before the end of region and ends
+// after its start; a zero-length record overlaps if its start is in region.
+// As with Bix.Query, a zero-width region returns the records
+// that overlap the base at its start and the chromosome may have a missing or
+// extra "chr" prefix.
+func (m *MemBix) Query(region interfaces.IPosition) (interfaces.RelatableIterator, error) {
+	if err := checkRegion(region); err != nil {
+		return nil, err
+	}
+	name, ok := canonicalContig(m.names, region.Chrom())
+	if !ok {
+		return &sliceIterator{}, nil
+	}
+	c := m.contigs[name]
+	start, end := inclusiveStart(region, m.bookended), exclusiveEnd(region, m.inclusiveEnd, m.bookended)
+	var recs []interfaces.Relatable
+	c.search(0, len(c.recs), start, end, func(r interfaces.Relatable) {
+		// a zero-length record is a point at its start.
+		if r.End() > start || (r.Start() == r.End() && r.Start() >= region.Start()) {
+			if m.maxSpan == 0 || int(r.End())-int(r.Start()) <= m.maxSpan {
+				recs = append(recs, r)
+			}
+		}
+	})
+	return &sliceIterator{recs: recs}, nil
+}
+
+// Len returns the number of records.
+func (m *MemBix) Len() int {
+	n := 0
+	for _, c := range m.contigs {
+		n += len(c.recs)
+	}
+	return n
+}
@@ -0,0 +1,110 @@
+package bix
+
+import (
+	"fmt"
+
+	"github.com/brentp/irelate/interfaces"
+	. "gopkg.in/check.v1"
+)
+
+func (s *BixSuite) TestLoadMemory(c *C) {
+	tbx, err := New(writeTabix(c, "mem.bed.gz", bedIndex(), []string{
+		"chr1\t10\t1000",
+		"chr1\t20\t30",
+		"chr1\t40\t50",
+		"chr1\t45\t46",
+		"chr2\t5\t6",
+	}))
+	c.Assert(err, IsNil)
+	defer tbx.Close()
+	tbx.SetReuseBuffers(true)
+
+	m, err := tbx.LoadMemory()
+	c.Assert(err, IsNil)
+	c.Check(m.Len(), Equals, 5)
+
+	for _, t := range []struct {
+		region interfaces.IPosition
+		starts []uint32
+	}{
+		{interfaces.AsIPosition("chr1", 0, 10), nil},
+		{interfaces.AsIPosition("chr1", 0, 11), []uint32{10}},
+		{interfaces.AsIPosition("chr1", 30, 40), []uint32{10}},
+		{interfaces.AsIPosition("chr1", 29, 45), []uint32{10, 20, 40}},
+		{interfaces.AsIPosition("1", 45, 46), []uint32{10, 40, 45}},
+		{interfaces.AsIPosition("chr1", 1000, 2000), nil},
+		{interfaces.AsIPosition("chr2", 0, 100), []uint32{5}},
+		{interfaces.AsIPosition("chr3", 0, 100), nil},
+	} {
+		it, err := m.Query(t.region)
+		c.Assert(err, IsNil)
+		var starts []uint32
+		for _, r := range collect(c, it) {
+			starts = append(starts, r.Start())
+		}
+		c.Check(starts, DeepEquals, t.starts, Commentf("%s:%d-%d", t.region.Chrom(), t.region.Start(), t.region.End()))
+	}
+
+	_, err = m.Query(interfaces.AsIPosition("chr1", 20, 10))
+	c.Check(err, NotNil)
+}
+
+// memLines returns a feature spanning the contig followed by n small records,
+// so that every query overlaps the first record.
+func memLines(n int) []string {
+	lines := []string{fmt.Sprintf("chr1\t0\t%d", n*128)}
+	for i := 0; i < n; i++ {
+		lines = append(lines, fmt.Sprintf("chr1\t%d\t%d", i*128+10, i*128+10+i%100))
+	}
+	return lines
+}
+
+func (s *BixSuite) TestLoadMemoryLongFeature(c *C) {
+	tbx, err := New(writeTabix(c, "memlong.bed.gz", bedIndex(), memLines(500)))
+	c.Assert(err, IsNil)
+	defer tbx.Close()
+	m, err := tbx.LoadMemory()
+	c.Assert(err, IsNil)
+
+	it, err := tbx.Query(nil)
+	c.Assert(err, IsNil)
+	all := collect(c, it)
+	c.Assert(all, HasLen, 501)
+	for start := 0; start < 500*128; start += 1001 {
+		end := start + 1 + start%300
+		// the records that overlap by a scan, a zero-length one if its start
+		// is in the region.
+		var want []string
+		for _, r := range all {
+			s, e := int(r.Start()), int(r.End())
+			if s < end && (e > start || (s == e && s >= start)) {
+				want = append(want, fmt.Sprintf("%d-%d", s, e))
+			}
+		}
+		it, err := m.Query(interfaces.AsIPosition("chr1", start, end))
+		c.Assert(err, IsNil)
+		var got []string
+		for _, r := range collect(c, it) {
+			got = append(got, fmt.Sprintf("%d-%d", r.Start(), r.End()))
+		}
+		c.Check(got, DeepEquals, want, Commentf("%d-%d", start, end))
+		c.Check(len(got) > 0 && got[0] == "0-64000", Equals, true)
+	}
+}
+
+func (s *BixSuite) BenchmarkMemQueryLongFeature(c *C) {
+	c.StopTimer()
+	n := 100000
+	tbx, err := New(writeTabixBlocks(c, "membench.bed.gz", bedIndex(), memLines(n), 100))
+	c.Assert(err, IsNil)
+	defer tbx.Close()
+	m, err := tbx.LoadMemory()
+	c.Assert(err, IsNil)
+	c.StartTimer()
+	for i := 0; i < c.N; i++ {
+		start := (i * 7919 % n) * 128
+		it, err := m.Query(interfaces.AsIPosition("chr1", start, start+200))
+		c.Assert(err, IsNil)
+		it.Close()
+	}
+}
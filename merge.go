@@ -0,0 +1,42 @@
+package bix
+
+import (
+	"io"
+
+	"github.com/brentp/irelate/interfaces"
+)
+
+// Interval is a 0-based, half-open span on a chromosome.
+type Interval struct {
+	Chrom      string
+	Start, End uint32
+}
+
+// MergedRegions returns the spans covered by the records in region, merging
+// records that overlap or that are separated by no more than maxGap bases,
+// as with bedtools merge -d. Book-ended records are merged with a maxGap of
+// 0. The spans are not clipped to region.
+func (tbx *Bix) MergedRegions(region interfaces.IPosition, maxGap int) ([]Interval, error) {
+	it, err := tbx.Query(region)
+	if err != nil {
+		return nil, err
+	}
+	defer it.Close()
+
+	var merged []Interval
+	for {
+		v, err := it.Next()
+		if err == io.EOF {
+			return merged, nil
+		} else if err != nil {
+			return nil, err
+		}
+		if n := len(merged); n > 0 && merged[n-1].Chrom == v.Chrom() && int64(v.Start()) <= int64(merged[n-1].End)+int64(maxGap) {
+			if v.End() > merged[n-1].End {
+				merged[n-1].End = v.End()
+			}
+			continue
+		}
+		merged = append(merged, Interval{Chrom: v.Chrom(), Start: v.Start(), End: v.End()})
+	}
+}
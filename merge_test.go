@@ -0,0 +1,37 @@
+package bix
+
+import (
+	"github.com/brentp/irelate/interfaces"
+	. "gopkg.in/check.v1"
+)
+
+func (s *BixSuite) TestMergedRegions(c *C) {
+	tbx, err := New(writeTabix(c, "merge.bed.gz", bedIndex(), []string{
+		"chr1\t10\t20",
+		"chr1\t15\t18",
+		"chr1\t20\t25",
+		"chr1\t30\t40",
+		"chr1\t35\t60",
+		"chr1\t100\t110",
+		"chr2\t5\t6",
+	}))
+	c.Assert(err, IsNil)
+	defer tbx.Close()
+
+	region := interfaces.AsIPosition("chr1", 0, 1000)
+	m, err := tbx.MergedRegions(region, 0)
+	c.Assert(err, IsNil)
+	c.Check(m, DeepEquals, []Interval{{"chr1", 10, 25}, {"chr1", 30, 60}, {"chr1", 100, 110}})
+
+	m, err = tbx.MergedRegions(region, 5)
+	c.Assert(err, IsNil)
+	c.Check(m, DeepEquals, []Interval{{"chr1", 10, 60}, {"chr1", 100, 110}})
+
+	m, err = tbx.MergedRegions(interfaces.AsIPosition("chr1", 36, 37), 0)
+	c.Assert(err, IsNil)
+	c.Check(m, DeepEquals, []Interval{{"chr1", 30, 60}})
+
+	m, err = tbx.MergedRegions(interfaces.AsIPosition("chr3", 0, 10), 0)
+	c.Assert(err, IsNil)
+	c.Check(m, HasLen, 0)
+}
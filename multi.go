@@ -0,0 +1,241 @@
+package bix
+
+import (
+	"io"
+	"strings"
+
+	"github.com/biogo/hts/bgzf"
+	"github.com/brentp/irelate/interfaces"
+	"github.com/brentp/vcfgo"
+	"github.com/pkg/errors"
+)
+
+// errMulti is returned by the methods that are not supported by a Bix from
+// NewMulti, or not for queries that span more than one of its files.
+var errMulti = errors.New("bix: not supported across the files of a Bix from NewMulti")
+
+// NewMulti returns a &Bix that presents the files in paths, such as a set of
+// per-chromosome VCFs, as a single source. Each file must be indexed and the
+// files must be compatible: all VCF with the same samples and no conflicting
+// INFO or FORMAT definitions, or all of the same format with the same
+// columns. For VCF, VReader holds a header merged from all of the files.
+//
+// A query is routed to the files whose index has the contig, in the order
+// of paths, and a nil region scans every file. Settings such as SetStrict
+// apply to all of the files.
+//
+// Methods that read a single file's index or offsets are not supported and
+// return an error: Clone, Genome, ParallelGenome, Validate, ChunkedReader,
+// MultiChunkedReader, ChunkInfo, MightOverlap, ContigExtentApprox,
+// BlockOffsets and ResumeFrom with a nonzero offset. Others return an error
+// for a query that spans more than one file: QueryFilter, QueryRaw,
+// QueryReverse, CopyRegion, Overlaps and CountByColumn for a nil region or a
+// contig in more than one file, QueryName, Diff and Intersect for a contig in
+// more than one file, and LoadMemory, ScanByContig, EstimateRecords and
+// Bounds, which read every file, whenever there is more than one.
+func NewMulti(paths []string, workers int) (*Bix, error) {
+	if len(paths) == 0 {
+		return nil, errors.New("bix: no paths given to NewMulti")
+	}
+	tbx := &Bix{path: strings.Join(paths, ","), workers: workers, delim: '\t', strandColumn: 6}
+	for _, p := range paths {
+		b, err := New(p, workers)
+		if err != nil {
+			tbx.Close()
+			return nil, err
+		}
+		tbx.multi = append(tbx.multi, b)
+	}
+	first := tbx.multi[0]
+	for i, b := range tbx.multi[1:] {
+		if err := compatible(first, b); err != nil {
+			tbx.Close()
+			return nil, errors.Wrapf(err, "bix: %s and %s are not compatible", paths[0], paths[i+1])
+		}
+	}
+
+	var names []string
+	seen := make(map[string]bool)
+	for _, b := range tbx.multi {
+		// the files are read through their own index from now on.
+		b.closeFile()
+		for _, n := range b.Names() {
+			if !seen[n] {
+				seen[n] = true
+				names = append(names, n)
+			}
+		}
+	}
+	tbx.Index = multiIndex{Index: first.Index, names: names}
+	tbx.refalt, tbx.columns = first.refalt, first.columns
+
+	if first.VReader != nil {
+		var err error
+		tbx.VReader, err = vcfgo.NewWithHeader(strings.NewReader(""), mergeHeaders(tbx.multi), true)
+		if err != nil {
+			tbx.Close()
+			return nil, errors.Wrapf(err, "bix: error creating vcf reader for %s", tbx.path)
+		}
+		// records from every file are parsed with the merged header.
+		for _, b := range tbx.multi {
+			b.VReader = tbx.VReader
+		}
+	}
+	return tbx, nil
+}
+
+// compatible returns an error if b cannot be queried along with a.
+func compatible(a, b *Bix) error {
+	if a.IsVCF() != b.IsVCF() {
+		return errors.New("only one is VCF")
+	}
+	if a.IsVCF() {
+		ha, hb := a.VReader.Header, b.VReader.Header
+		if strings.Join(ha.SampleNames, "\t") != strings.Join(hb.SampleNames, "\t") {
+			return errors.New("samples differ")
+		}
+		for id, i := range hb.Infos {
+			if j, ok := ha.Infos[id]; ok && (i.Number != j.Number || i.Type != j.Type) {
+				return errors.Errorf("INFO %s is defined differently", id)
+			}
+		}
+		for id, i := range hb.SampleFormats {
+			if j, ok := ha.SampleFormats[id]; ok && (i.Number != j.Number || i.Type != j.Type) {
+				return errors.Errorf("FORMAT %s is defined differently", id)
+			}
+		}
+		return nil
+	}
+	if a.Format() != b.Format() || a.NameColumn() != b.NameColumn() ||
+		a.BeginColumn() != b.BeginColumn() || a.EndColumn() != b.EndColumn() {
+		return errors.New("index formats or columns differ")
+	}
+	return nil
+}
+
+// mergeHeaders returns the header of the first file with the INFO, FORMAT,
+// FILTER and contig lines of the others added.
+func mergeHeaders(bs []*Bix) *vcfgo.Header {
+	first := bs[0].VReader.Header
+	h := &vcfgo.Header{
+		SampleNames:   first.SampleNames,
+		Infos:         make(map[string]*vcfgo.Info),
+		SampleFormats: make(map[string]*vcfgo.SampleFormat),
+		Filters:       make(map[string]string),
+		Extras:        first.Extras,
+		FileFormat:    first.FileFormat,
+		Samples:       first.Samples,
+		Pedigrees:     first.Pedigrees,
+	}
+	contigs := make(map[string]bool)
+	for _, b := range bs {
+		o := b.VReader.Header
+		for id, i := range o.Infos {
+			if _, ok := h.Infos[id]; !ok {
+				h.Infos[id] = i
+			}
+		}
+		for id, f := range o.SampleFormats {
+			if _, ok := h.SampleFormats[id]; !ok {
+				h.SampleFormats[id] = f
+			}
+		}
+		for id, f := range o.Filters {
+			if _, ok := h.Filters[id]; !ok {
+				h.Filters[id] = f
+			}
+		}
+		for _, c := range o.Contigs {
+			if !contigs[c["ID"]] {
+				contigs[c["ID"]] = true
+				h.Contigs = append(h.Contigs, c)
+			}
+		}
+	}
+	return h
+}
+
+// multiIndex is the Index of a Bix from NewMulti. It has the contigs of all
+// of the files and otherwise describes the first.
+type multiIndex struct {
+	Index
+	names []string
+}
+
+func (m multiIndex) Names() []string { return m.names }
+
+func (m multiIndex) NumRefs() int { return len(m.names) }
+
+func (m multiIndex) Chunks(string, int, int) ([]bgzf.Chunk, error) {
+	return nil, errMulti
+}
+
+// queryMulti routes a Query on a Bix from NewMulti to its files.
+func (tbx *Bix) queryMulti(region interfaces.IPosition) (interfaces.RelatableIterator, error) {
+	var files []*Bix
+	for _, b := range tbx.multi {
+		if region == nil {
+			files = append(files, b)
+		} else if _, ok := aliasedContig(b.Names(), tbx.aliases, region.Chrom()); ok {
+			files = append(files, b)
+		}
+	}
+	if len(files) == 0 {
+		// the first file reports the unknown contig as configured.
+		files = tbx.multi[:1]
+	}
+	if len(files) == 1 {
+		return tbx.queryFile(files[0], region)
+	}
+	return &multiIterator{tbx: tbx, files: files, region: region}, nil
+}
+
+// queryFile queries one of the files of tbx with the settings of tbx.
+func (tbx *Bix) queryFile(b *Bix, region interfaces.IPosition) (interfaces.RelatableIterator, error) {
+	b2, err := newShort(b)
+	if err != nil {
+		return nil, err
+	}
+	b2.copySettings(tbx)
+	return b2.query(region)
+}
+
+// multiIterator iterates over a query on each of files in turn.
+type multiIterator struct {
+	tbx    *Bix
+	files  []*Bix
+	region interfaces.IPosition
+	cur    interfaces.RelatableIterator
+}
+
+func (m *multiIterator) Next() (interfaces.Relatable, error) {
+	for {
+		if m.cur == nil {
+			if len(m.files) == 0 {
+				return nil, io.EOF
+			}
+			var err error
+			m.cur, err = m.tbx.queryFile(m.files[0], m.region)
+			if err != nil {
+				return nil, err
+			}
+			m.files = m.files[1:]
+		}
+		v, err := m.cur.Next()
+		if err != io.EOF {
+			return v, err
+		}
+		m.cur.Close()
+		m.cur = nil
+	}
+}
+
+func (m *multiIterator) Close() error {
+	m.files = nil
+	if m.cur == nil {
+		return nil
+	}
+	err := m.cur.Close()
+	m.cur = nil
+	return err
+}
@@ -0,0 +1,112 @@
+package bix
+
+import (
+	"fmt"
+
+	"github.com/biogo/hts/tabix"
+	"github.com/brentp/irelate/interfaces"
+	"github.com/pkg/errors"
+	. "gopkg.in/check.v1"
+)
+
+// chromVCF writes a VCF with a record at each of the 1-based positions on
+// chrom, with the extra header lines added before the #CHROM line.
+func chromVCF(c *C, chrom string, samples string, extra []string, pos ...int) string {
+	idx := tabix.New()
+	idx.NameColumn, idx.BeginColumn, idx.EndColumn = 1, 2, 0
+	idx.MetaChar = '#'
+	lines := []string{"##fileformat=VCFv4.1", `##INFO=<ID=DP,Number=1,Type=Integer,Description="Depth">`}
+	lines = append(lines, extra...)
+	lines = append(lines, "#CHROM\tPOS\tID\tREF\tALT\tQUAL\tFILTER\tINFO\tFORMAT\t"+samples)
+	for _, p := range pos {
+		lines = append(lines, fmt.Sprintf("%s\t%d\t.\tA\tT\t50\tPASS\tDP=%d\tGT\t0/1", chrom, p, p))
+	}
+	return writeTabix(c, chrom+".vcf.gz", idx, lines)
+}
+
+func (s *BixSuite) TestMulti(c *C) {
+	tbx, err := NewMulti([]string{
+		chromVCF(c, "chr1", "S1", nil, 100, 200),
+		chromVCF(c, "chr2", "S1", []string{`##INFO=<ID=AF,Number=A,Type=Float,Description="Frequency">`}, 50),
+	}, 1)
+	c.Assert(err, IsNil)
+	defer tbx.Close()
+
+	c.Check(tbx.IsVCF(), Equals, true)
+	c.Check(tbx.Names(), DeepEquals, []string{"chr1", "chr2"})
+	c.Check(tbx.GetHeaderType("DP"), Equals, "Integer")
+	c.Check(tbx.GetHeaderType("AF"), Equals, "Float")
+
+	it, err := tbx.Query(interfaces.AsIPosition("chr2", 0, 100))
+	c.Assert(err, IsNil)
+	recs := collect(c, it)
+	c.Assert(recs, HasLen, 1)
+	c.Check(recs[0].Chrom(), Equals, "chr2")
+	c.Check(recs[0].Start(), Equals, uint32(49))
+
+	it, err = tbx.FastQuery(interfaces.AsIPosition("1", 150, 300))
+	c.Assert(err, IsNil)
+	recs = collect(c, it)
+	c.Assert(recs, HasLen, 1)
+	c.Check(recs[0].Start(), Equals, uint32(199))
+
+	it, err = tbx.Query(nil)
+	c.Assert(err, IsNil)
+	var got []string
+	for _, r := range collect(c, it) {
+		got = append(got, fmt.Sprintf("%s:%d", r.Chrom(), r.Start()))
+	}
+	c.Check(got, DeepEquals, []string{"chr1:99", "chr1:199", "chr2:49"})
+
+	// settings on the multi Bix apply to each file.
+	tbx.SetStrict(true)
+	_, err = tbx.Query(interfaces.AsIPosition("chr3", 0, 100))
+	c.Check(err, NotNil)
+
+	_, err = tbx.Clone()
+	c.Check(err, Equals, errMulti)
+	_, err = tbx.QueryRaw(nil)
+	c.Check(err, Equals, errMulti)
+	raw, err := tbx.QueryRaw(interfaces.AsIPosition("chr1", 0, 150))
+	c.Assert(err, IsNil)
+	line, err := raw.Next()
+	c.Assert(err, IsNil)
+	c.Check(string(line), Equals, "chr1\t100\t.\tA\tT\t50\tPASS\tDP=100\tGT\t0/1")
+	raw.Close()
+
+	// the methods that read one file's index or all of the files fail.
+	_, err = tbx.Validate()
+	c.Check(err, Equals, errMulti)
+	_, err = tbx.MultiChunkedReader(interfaces.AsIPosition("chr1", 0, 150))
+	c.Check(err, Equals, errMulti)
+	_, err = tbx.BlockOffsets()
+	c.Check(err, Equals, errMulti)
+	_, _, err = tbx.ChunkInfo("chr1", 0, 150)
+	c.Check(errors.Cause(err), Equals, errMulti)
+	_, err = tbx.MightOverlap("chr1", 0, 150)
+	c.Check(errors.Cause(err), Equals, errMulti)
+	err = tbx.ScanByContig(func(string, interfaces.RelatableIterator) error { return nil })
+	c.Check(err, Equals, errMulti)
+	_, _, err = tbx.EstimateRecords()
+	c.Check(err, Equals, errMulti)
+	_, err = tbx.CountByColumn(nil, 4)
+	c.Check(err, Equals, errMulti)
+	counts, err := tbx.CountByColumn(interfaces.AsIPosition("chr1", 0, 150), 4)
+	c.Assert(err, IsNil)
+	c.Check(counts, DeepEquals, map[string]int{"A": 1})
+}
+
+func (s *BixSuite) TestMultiIncompatible(c *C) {
+	a := chromVCF(c, "chr1", "S1", nil, 100)
+	_, err := NewMulti([]string{a, chromVCF(c, "chr2", "S2", nil, 100)}, 1)
+	c.Check(err, ErrorMatches, ".*samples differ")
+
+	_, err = NewMulti([]string{a, chromVCF(c, "chr2", "S1", []string{`##INFO=<ID=DP,Number=1,Type=Float,Description="Depth">`}, 100)}, 1)
+	c.Check(err, ErrorMatches, ".*INFO DP is defined differently")
+
+	_, err = NewMulti([]string{a, writeTabix(c, "a.bed.gz", bedIndex(), []string{"chr2\t1\t10"})}, 1)
+	c.Check(err, ErrorMatches, ".*only one is VCF")
+
+	_, err = NewMulti(nil, 1)
+	c.Check(err, NotNil)
+}
@@ -0,0 +1,162 @@
+package bix
+
+import (
+	"io"
+	"sync"
+
+	"github.com/brentp/irelate/interfaces"
+)
+
+// batchSize is the number of records that a ParallelGenome worker sends at
+// a time.
+const batchSize = 256
+
+// recordBatch is a batch of records from a contig or the error that stopped
+// its query.
+type recordBatch struct {
+	recs []interfaces.Relatable
+	err  error
+}
+
+// ParallelGenome is like Genome but reads up to workers contigs at a time,
+// each in its own goroutine with its own file handle from Clone. Records are
+// returned in the same order as from Genome, each contig in index order, so
+// the output does not depend on the number of workers. Only a few batches of
+// records are buffered for each contig that is ahead of the one being
+// returned, so memory use stays bounded. Records are not reused between calls
+// to Next even if SetReuseBuffers has been called. The iterator must be
+// closed to stop the workers. It returns an error for a Bix from NewMulti.
+func (tbx *Bix) ParallelGenome(workers int) (interfaces.RelatableIterator, error) {
+	if workers < 1 {
+		workers = 1
+	}
+	names := tbx.Names()
+	if workers > len(names) {
+		workers = len(names)
+	}
+	clones := make([]*Bix, 0, workers)
+	for i := 0; i < workers; i++ {
+		c, err := tbx.Clone()
+		if err != nil {
+			for _, c := range clones {
+				c.Close()
+			}
+			return nil, err
+		}
+		// each worker makes one query at a time, so they can share its file.
+		c.serial = true
+		c.reuse = false
+		clones = append(clones, c)
+	}
+
+	// contigs are handed out in index order, so the contig being returned
+	// has always been started by a worker.
+	jobs := make(chan int, len(names))
+	for i := range names {
+		jobs <- i
+	}
+	close(jobs)
+
+	p := &parallelIterator{results: make([]chan recordBatch, len(names)), done: make(chan struct{})}
+	for i := range p.results {
+		p.results[i] = make(chan recordBatch, 4)
+	}
+	for _, c := range clones {
+		p.wg.Add(1)
+		go p.work(c, names, jobs)
+	}
+	return p, nil
+}
+
+// parallelIterator returns the records sent by the workers of
+// ParallelGenome for each contig in turn.
+type parallelIterator struct {
+	results []chan recordBatch
+	cur     int
+	recs    []interfaces.Relatable
+	err     error
+
+	// closed to stop the workers.
+	done   chan struct{}
+	wg     sync.WaitGroup
+	closed bool
+}
+
+func (p *parallelIterator) work(tbx *Bix, names []string, jobs <-chan int) {
+	defer p.wg.Done()
+	defer tbx.Close()
+	for i := range jobs {
+		if !p.read(tbx, i, names[i]) {
+			return
+		}
+	}
+}
+
+// read sends the records on chrom to results[i] in batches. It returns false
+// if the iterator has been closed.
+func (p *parallelIterator) read(tbx *Bix, i int, chrom string) bool {
+	defer close(p.results[i])
+	it, err := tbx.QueryChrom(chrom)
+	if err != nil {
+		return p.send(i, recordBatch{err: err})
+	}
+	defer it.Close()
+
+	recs := make([]interfaces.Relatable, 0, batchSize)
+	for {
+		v, err := it.Next()
+		if err == io.EOF {
+			return len(recs) == 0 || p.send(i, recordBatch{recs: recs})
+		} else if err != nil {
+			return p.send(i, recordBatch{recs: recs, err: err})
+		}
+		recs = append(recs, v)
+		if len(recs) == batchSize {
+			if !p.send(i, recordBatch{recs: recs}) {
+				return false
+			}
+			recs = make([]interfaces.Relatable, 0, batchSize)
+		}
+	}
+}
+
+// send sends b to results[i], returning false if the iterator was closed
+// first.
+func (p *parallelIterator) send(i int, b recordBatch) bool {
+	select {
+	case p.results[i] <- b:
+		return true
+	case <-p.done:
+		return false
+	}
+}
+
+func (p *parallelIterator) Next() (interfaces.Relatable, error) {
+	for len(p.recs) == 0 {
+		if p.err != nil {
+			return nil, p.err
+		}
+		if p.closed || p.cur == len(p.results) {
+			return nil, io.EOF
+		}
+		b, ok := <-p.results[p.cur]
+		if !ok {
+			p.cur++
+			continue
+		}
+		p.recs, p.err = b.recs, b.err
+	}
+	v := p.recs[0]
+	p.recs = p.recs[1:]
+	return v, nil
+}
+
+func (p *parallelIterator) Close() error {
+	if p.closed {
+		return nil
+	}
+	p.closed = true
+	close(p.done)
+	p.wg.Wait()
+	return nil
+}
@@ -0,0 +1,50 @@
+package bix
+
+import (
+	"fmt"
+
+	"github.com/brentp/irelate/interfaces"
+	. "gopkg.in/check.v1"
+)
+
+func (s *BixSuite) TestParallelGenome(c *C) {
+	var lines []string
+	for _, chrom := range []string{"chr1", "chr2", "chr3", "chr10", "chrX"} {
+		for i := 0; i < 700; i++ {
+			lines = append(lines, fmt.Sprintf("%s\t%d\t%d\t%s-%d", chrom, i*10, i*10+5, chrom, i))
+		}
+	}
+	tbx, err := New(writeTabix(c, "genome.bed.gz", bedIndex(), lines))
+	c.Assert(err, IsNil)
+	defer tbx.Close()
+
+	names := func(it interfaces.RelatableIterator) []string {
+		var names []string
+		for _, r := range collect(c, it) {
+			names = append(names, string(recordFields(r)[3]))
+		}
+		return names
+	}
+	it, err := tbx.Genome()
+	c.Assert(err, IsNil)
+	want := names(it)
+	c.Assert(want, HasLen, len(lines))
+
+	// records must not be reused as they are buffered.
+	tbx.SetReuseBuffers(true)
+
+	for _, workers := range []int{0, 1, 3, 10} {
+		it, err := tbx.ParallelGenome(workers)
+		c.Assert(err, IsNil)
+		c.Check(names(it), DeepEquals, want, Commentf("%d workers", workers))
+	}
+
+	// closing early stops the workers.
+	it, err = tbx.ParallelGenome(2)
+	c.Assert(err, IsNil)
+	for i := 0; i < 10; i++ {
+		_, err := it.Next()
+		c.Assert(err, IsNil)
+	}
+	c.Check(it.Close(), IsNil)
+}
@@ -0,0 +1,141 @@
+package bix
+
+import (
+	"bytes"
+	"io"
+
+	"github.com/brentp/irelate/interfaces"
+	"github.com/brentp/irelate/parsers"
+)
+
+// SetRawLines determines whether records returned from queries keep a copy
+// of the line they were parsed from. The line can be retrieved with RawLine.
+// When this is on, generic records are not *parsers.Interval values, but
+// still satisfy the same interfaces (including interfaces.IRefAlt where
+// applicable), and VCF records still satisfy interfaces.IVariant.
+func (tbx *Bix) SetRawLines(keep bool) {
+	tbx.rawLines = keep
+}
+
+// RawLine returns the original text (without the line terminator) of a
+// record returned from a Bix with SetRawLines(true). It returns nil for any
+// other record.
+func RawLine(r interfaces.Relatable) []byte {
+	if rl, ok := r.(rawLiner); ok {
+		return rl.rawLine()
+	}
+	return nil
+}
+
+// LineIterator returns the lines from a query without parsing them.
+type LineIterator struct {
+	bx *bixerator
+}
+
+// QueryRaw is like Query but returns the lines in region, without their line
+// terminators, rather than parsed records. This is the cheapest way to
+// extract the lines in a region.
+func (tbx *Bix) QueryRaw(region interfaces.IPosition) (*LineIterator, error) {
+	bx, err := tbx.queryBixerator(region)
+	if err != nil {
+		return nil, err
+	}
+	return &LineIterator{bx: bx}, nil
+}
+
+// Next returns a copy of the next line or io.EOF when there are no more.
+func (l *LineIterator) Next() ([]byte, error) {
+	_, raw, err := l.bx.readToks()
+	if err != nil {
+		if err == io.EOF {
+			l.Close()
+		}
+		return nil, err
+	}
+	return append([]byte(nil), bytes.TrimRight(raw, "\r\n")...), nil
+}
+
+// Close closes the underlying file.
+func (l *LineIterator) Close() error {
+	return l.bx.Close()
+}
+
+type rawLiner interface {
+	rawLine() []byte
+}
+
+type rawVariant struct {
+	interfaces.VarWrap
+	raw []byte
+}
+
+func (r *rawVariant) rawLine() []byte { return r.raw }
+
+type rawInterval struct {
+	*parsers.Interval
+	raw []byte
+}
+
+func (r *rawInterval) rawLine() []byte { return r.raw }
+
+type rawRefAltInterval struct {
+	*parsers.RefAltInterval
+	raw []byte
+}
+
+func (r *rawRefAltInterval) rawLine() []byte { return r.raw }
+
+// withRaw wraps v with a copy of the line it was parsed from.
+func withRaw(v interfaces.Relatable, line []byte) interfaces.Relatable {
+	line = append([]byte(nil), bytes.TrimRight(line, "\r\n")...)
+	switch r := v.(type) {
+	case interfaces.VarWrap:
+		return &rawVariant{VarWrap: r, raw: line}
+	case *parsers.RefAltInterval:
+		return &rawRefAltInterval{RefAltInterval: r, raw: line}
+	case *parsers.Interval:
+		return &rawInterval{Interval: r, raw: line}
+	}
+	return v
+}
+
+var _ interfaces.IVariant = (*rawVariant)(nil)
+var _ interfaces.Relatable = (*rawVariant)(nil)
+var _ interfaces.IRefAlt = (*rawRefAltInterval)(nil)
+var _ interfaces.Relatable = (*rawRefAltInterval)(nil)
+var _ interfaces.Relatable = (*rawInterval)(nil)
+
+// AppendInfo returns a copy of line, a raw VCF line, with key=value added to
+// the end of its INFO column, or with just key if value is empty, as for a
+// flag. An INFO of "." is replaced. The other columns are unchanged. It does
+// not check whether key is already present. A line with fewer than 8 columns
+// is returned unchanged.
+func AppendInfo(line []byte, key, value string) []byte {
+	// find the start and end of the 8th column.
+	start := 0
+	for i := 0; i < 7; i++ {
+		j := bytes.IndexByte(line[start:], '\t')
+		if j == -1 {
+			return line
+		}
+		start += j + 1
+	}
+	end := start + bytes.IndexAny(line[start:], "\t\r\n")
+	if end < start {
+		end = len(line)
+	}
+
+	kv := key
+	if value != "" {
+		kv += "=" + value
+	}
+	out := make([]byte, 0, len(line)+len(kv)+1)
+	out = append(out, line[:end]...)
+	if end-start == 1 && line[start] == '.' {
+		out = out[:start]
+	} else if end > start {
+		out = append(out, ';')
+	}
+	out = append(out, kv...)
+	return append(out, line[end:]...)
+}
@@ -0,0 +1,107 @@
+package bix
+
+import (
+	"io"
+	"strings"
+
+	"github.com/brentp/irelate/interfaces"
+	. "gopkg.in/check.v1"
+)
+
+func (s *BixSuite) TestRawLine(c *C) {
+	tbx, err := New("main/NA12878.wham.del.vcf.gz")
+	c.Assert(err, IsNil)
+	defer tbx.Close()
+
+	region := interfaces.AsIPosition("1", 755600, 755700)
+	it, err := tbx.Query(region)
+	c.Assert(err, IsNil)
+	recs := collect(c, it)
+	c.Assert(recs, HasLen, 1)
+	c.Check(RawLine(recs[0]), IsNil)
+
+	tbx.SetRawLines(true)
+	it, err = tbx.Query(region)
+	c.Assert(err, IsNil)
+	recs = collect(c, it)
+	c.Assert(recs, HasLen, 1)
+	line := string(RawLine(recs[0]))
+	c.Check(strings.HasPrefix(line, "1\t755638\tWG:DEL:e085a96c\tA\t<DEL>\t.\t.\tSVTYPE=DEL;"), Equals, true)
+	c.Check(line[len(line)-1], Not(Equals), byte('\n'))
+	v, ok := recs[0].(interfaces.IVariant)
+	c.Assert(ok, Equals, true)
+	c.Check(v.Ref(), Equals, "A")
+
+	bed, err := New(writeTabix(c, "raw.bed.gz", bedIndex(), []string{
+		"#chrom\tstart\tend\tref\talt",
+		"chr1\t10\t20\tA\tT\r",
+	}))
+	c.Assert(err, IsNil)
+	defer bed.Close()
+	bed.SetRawLines(true)
+	it, err = bed.Query(interfaces.AsIPosition("chr1", 0, 100))
+	c.Assert(err, IsNil)
+	recs = collect(c, it)
+	c.Assert(recs, HasLen, 1)
+	c.Check(string(RawLine(recs[0])), Equals, "chr1\t10\t20\tA\tT")
+	ra, ok := recs[0].(interfaces.IRefAlt)
+	c.Assert(ok, Equals, true)
+	c.Check(ra.Alt(), DeepEquals, []string{"T"})
+}
+
+func (s *BixSuite) TestQueryRaw(c *C) {
+	lines := []string{
+		"#chrom\tstart\tend",
+		"chr1\t10\t20\ta",
+		"chr1\t30\t40\tb\r",
+		"chr2\t5\t6\tc",
+	}
+	tbx, err := New(writeTabix(c, "raw.bed.gz", bedIndex(), lines))
+	c.Assert(err, IsNil)
+	defer tbx.Close()
+
+	for _, t := range []struct {
+		region interfaces.IPosition
+		want   []string
+	}{
+		{nil, []string{"chr1\t10\t20\ta", "chr1\t30\t40\tb", "chr2\t5\t6\tc"}},
+		{interfaces.AsIPosition("chr1", 25, 35), []string{"chr1\t30\t40\tb"}},
+		{interfaces.AsIPosition("chr2", 0, 5), nil},
+	} {
+		it, err := tbx.QueryRaw(t.region)
+		c.Assert(err, IsNil)
+		var got []string
+		var prev []byte
+		for {
+			l, err := it.Next()
+			if err == io.EOF {
+				break
+			}
+			c.Assert(err, IsNil)
+			if prev != nil {
+				// lines are copies.
+				c.Check(string(prev), Equals, got[len(got)-1])
+			}
+			prev = l
+			got = append(got, string(l))
+		}
+		c.Check(it.Close(), IsNil)
+		c.Check(got, DeepEquals, t.want)
+	}
+}
+
+func (s *BixSuite) TestAppendInfo(c *C) {
+	for _, t := range []struct {
+		line, key, value, want string
+	}{
+		{"chr1\t10\t.\tA\tT\t50\tPASS\tDP=3\tGT\t0/1\n", "AF", "0.5", "chr1\t10\t.\tA\tT\t50\tPASS\tDP=3;AF=0.5\tGT\t0/1\n"},
+		{"chr1\t10\t.\tA\tT\t50\tPASS\t.\tGT\t0/1", "AF", "0.5", "chr1\t10\t.\tA\tT\t50\tPASS\tAF=0.5\tGT\t0/1"},
+		{"chr1\t10\t.\tA\tT\t50\tPASS\tDP=3", "SOMATIC", "", "chr1\t10\t.\tA\tT\t50\tPASS\tDP=3;SOMATIC"},
+		{"chr1\t10\t.\tA\tT\t50\tPASS\t\r\n", "AF", "1", "chr1\t10\t.\tA\tT\t50\tPASS\tAF=1\r\n"},
+		{"chr1\t10\t.\tA\tT\t50\tPASS", "AF", "1", "chr1\t10\t.\tA\tT\t50\tPASS"},
+	} {
+		line := []byte(t.line)
+		c.Check(string(AppendInfo(line, t.key, t.value)), Equals, t.want)
+		c.Check(string(line), Equals, t.line)
+	}
+}
@@ -0,0 +1,91 @@
+package bix
+
+import (
+	"math"
+	"strconv"
+	"strings"
+
+	"github.com/brentp/irelate/interfaces"
+	"github.com/pkg/errors"
+)
+
+// maxEnd is used as the end of regions that extend to the end of a contig.
+const maxEnd = math.MaxInt32
+
+// tileWidth is the width of a tile of the linear index of a tabix file.
+const tileWidth = 1 << 14
+
+// ParseRegion parses a region string of the form "chr1", "chr1:1000" or
+// "chr1:1000-2000". As with samtools, the coordinates are 1-based and
+// inclusive and may contain commas (e.g. "chr1:1,000-2,000"). "chr1" covers
+// the entire contig and "chr1:1000" covers the single base at 1000. Either
+// end of a range may be left open: "chr1:1000-" extends to the end of the
+// contig and "chr1:-2000" starts at its beginning. The returned IPosition has
+// 0-based, half-open coordinates; the end of a region that extends to the end
+// of the contig is the greatest position that can be queried.
+func ParseRegion(s string) (interfaces.IPosition, error) {
+	s = strings.TrimSpace(s)
+	chrom, rng := s, ""
+	if i := strings.LastIndexByte(s, ':'); i != -1 {
+		chrom, rng = s[:i], s[i+1:]
+		if rng == "" {
+			return nil, errors.Errorf("bix: invalid region %q: missing range after ':'", s)
+		}
+	}
+	if chrom == "" {
+		return nil, errors.Errorf("bix: invalid region %q: missing chromosome", s)
+	}
+	if rng == "" {
+		return interfaces.AsIPosition(chrom, 0, maxEnd), nil
+	}
+
+	sstart, send := rng, rng
+	if i := strings.IndexByte(rng, '-'); i != -1 {
+		sstart, send = rng[:i], rng[i+1:]
+		if sstart == "" && send == "" {
+			return nil, errors.Errorf("bix: invalid region %q: missing start and end", s)
+		}
+	}
+	start, end := 1, maxEnd
+	var err error
+	if sstart != "" {
+		if start, err = parsePosition(sstart); err != nil {
+			return nil, errors.Wrapf(err, "bix: invalid start in region %q", s)
+		}
+	}
+	if send != "" {
+		if end, err = parsePosition(send); err != nil {
+			return nil, errors.Wrapf(err, "bix: invalid end in region %q", s)
+		}
+	}
+	if end < start {
+		return nil, errors.Errorf("bix: invalid region %q: end is before start", s)
+	}
+	return interfaces.AsIPosition(chrom, start-1, end), nil
+}
+
+// parsePosition parses a 1-based position that may contain commas.
+func parsePosition(s string) (int, error) {
+	p, err := strconv.Atoi(strings.Replace(s, ",", "", -1))
+	if err != nil {
+		return 0, err
+	}
+	if p < 1 || p > maxEnd {
+		return 0, errors.Errorf("position %d out of range", p)
+	}
+	return p, nil
+}
+
+// QueryString is Query for a region string as accepted by ParseRegion. It
+// returns an error with ErrUnknownContig as its cause if the contig is not
+// in the index, whether or not strict mode is on.
+func (tbx *Bix) QueryString(s string) (interfaces.RelatableIterator, error) {
+	region, err := ParseRegion(s)
+	if err != nil {
+		return nil, err
+	}
+	if _, ok := tbx.CanonicalContig(region.Chrom()); !ok {
+		return nil, errors.Wrapf(ErrUnknownContig, "%s not found in %s", region.Chrom(), tbx.path)
+	}
+	return tbx.Query(region)
+}
@@ -0,0 +1,59 @@
+package bix
+
+import (
+	"github.com/pkg/errors"
+	. "gopkg.in/check.v1"
+)
+
+func (s *BixSuite) TestParseRegion(c *C) {
+	for _, t := range []struct {
+		in         string
+		chrom      string
+		start, end uint32
+	}{
+		{"chr1", "chr1", 0, maxEnd},
+		{"chr1:1000", "chr1", 999, 1000},
+		{"chr1:1000-2000", "chr1", 999, 2000},
+		{"chr1:1,000-2,000", "chr1", 999, 2000},
+		{" 2:1-1 ", "2", 0, 1},
+		{"HLA-A*01:01:01:01:1-10", "HLA-A*01:01:01:01", 0, 10},
+		{"chr1:1,000-", "chr1", 999, maxEnd},
+		{"chr1:-2000", "chr1", 0, 2000},
+	} {
+		r, err := ParseRegion(t.in)
+		c.Assert(err, IsNil, Commentf(t.in))
+		c.Check(r.Chrom(), Equals, t.chrom)
+		c.Check(r.Start(), Equals, t.start, Commentf(t.in))
+		c.Check(r.End(), Equals, t.end, Commentf(t.in))
+	}
+
+	for _, in := range []string{"", ":1-10", "chr1:", "chr1:a-10", "chr1:10-b", "chr1:0-10", "chr1:20-10", "chr1:-", "chr1:0-", "chr1:-x"} {
+		_, err := ParseRegion(in)
+		c.Check(err, NotNil, Commentf(in))
+	}
+}
+
+func (s *BixSuite) TestQueryString(c *C) {
+	tbx, err := New(writeTabix(c, "string.bed.gz", bedIndex(), scanLines(10)))
+	c.Assert(err, IsNil)
+	defer tbx.Close()
+
+	for _, t := range []struct {
+		in string
+		n  int
+	}{
+		{"chr1", 10},
+		{"chr1:41-", 5},
+		{"1:-16", 2},
+		{"chr1:17-24", 1},
+	} {
+		it, err := tbx.QueryString(t.in)
+		c.Assert(err, IsNil, Commentf(t.in))
+		c.Check(collect(c, it), HasLen, t.n, Commentf(t.in))
+	}
+
+	_, err = tbx.QueryString("chr2:-10")
+	c.Check(errors.Cause(err), Equals, ErrUnknownContig)
+	_, err = tbx.QueryString("chr1:x-")
+	c.Check(err, NotNil)
+}
@@ -0,0 +1,292 @@
+package bix
+
+import (
+	"io"
+	"sort"
+
+	"github.com/biogo/hts/bgzf/index"
+	"github.com/brentp/irelate/interfaces"
+	"github.com/pkg/errors"
+)
+
+// number of records read by EstimateRecords when the index does not
+// have record counts.
+const estimateSample = 10000
+
+type referenceStatser interface {
+	ReferenceStats(id int) (index.ReferenceStats, bool)
+}
+
+// EstimateRecords returns the number of records in the file. If the index
+// holds per-reference record counts (as written by htslib), the count is
+// exact. Otherwise, it is estimated from the compressed size of a sample of
+// records at the start of the file and exact is false.
+func (tbx *Bix) EstimateRecords() (n int64, exact bool, err error) {
+	if n, ok := tbx.indexedRecords(); ok {
+		return n, true, nil
+	}
+	return tbx.sampleRecords(estimateSample)
+}
+
+// indexedRecords sums the record counts stored in the index.
+func (tbx *Bix) indexedRecords() (int64, bool) {
+	rs, ok := tbx.Index.(referenceStatser)
+	if !ok || tbx.Index.NumRefs() == 0 {
+		return 0, false
+	}
+	var n int64
+	for i := 0; i < tbx.Index.NumRefs(); i++ {
+		st, ok := rs.ReferenceStats(i)
+		if !ok {
+			return 0, false
+		}
+		n += int64(st.Mapped + st.Unmapped)
+	}
+	return n, true
+}
+
+// sampleRecords reads up to max records from the start of the file and
+// extrapolates to the size of the file. If the end of the file is reached,
+// the count is exact.
+func (tbx *Bix) sampleRecords(max int) (int64, bool, error) {
+	bx, err := tbx.queryBixerator(nil)
+	if err != nil {
+		return 0, false, err
+	}
+	defer bx.Close()
+
+	var n, size int64
+	start := int64(-1)
+	for n < int64(max) {
+		_, raw, err := bx.readToks()
+		if err == io.EOF {
+			return n, true, nil
+		} else if err != nil {
+			return 0, false, err
+		}
+		if start == -1 {
+			start = bx.tbx.bgzf.LastChunk().Begin.File
+		}
+		size += int64(len(raw))
+		n++
+	}
+	// bytes that have been decompressed but are still buffered.
+	buffered := int64(bx.buf.Buffered())
+	end := bx.tbx.bgzf.LastChunk().End.File
+	fileSize, err := dataSize(bx.tbx.file)
+	if err != nil {
+		return 0, false, errors.Wrapf(err, "bix: error getting size of %s", tbx.path)
+	}
+	if end <= start {
+		end = start + 1
+	}
+	total := float64(fileSize-start) / float64(end-start) * float64(size+buffered)
+	return int64(total / (float64(size) / float64(n))), false, nil
+}
+
+// ContigExtent returns the 0-based start of the first record on chrom and
+// the greatest end of any record on it. The end is found with a binary
+// search of queries that each read only the chunks that the index gives for
+// the end of the contig, so it is exact but reads far less than a scan of
+// the contig. It returns an error if the contig is not in the index or has no
+// records.
+func (tbx *Bix) ContigExtent(chrom string) (min, max int, err error) {
+	name, ok := tbx.CanonicalContig(chrom)
+	if !ok {
+		return 0, 0, errors.Wrapf(ErrUnknownContig, "%s not found in %s", chrom, tbx.path)
+	}
+	it, err := tbx.Query(interfaces.AsIPosition(name, 0, maxEnd))
+	if err != nil {
+		return 0, 0, err
+	}
+	first, err := it.Next()
+	if err == io.EOF {
+		it.Close()
+		return 0, 0, errors.Errorf("bix: no records on %s in %s", chrom, tbx.path)
+	} else if err != nil {
+		it.Close()
+		return 0, 0, err
+	}
+	min, max = int(first.Start()), int(first.End())
+	it.Close()
+
+	// find the least x for which no record ends after x.
+	var qerr error
+	x := min + sort.Search(maxEnd-min, func(i int) bool {
+		if qerr != nil {
+			return true
+		}
+		var found bool
+		found, qerr = tbx.endsAfter(name, min+i)
+		return !found
+	})
+	if qerr != nil {
+		return 0, 0, qerr
+	}
+	if x > max {
+		max = x
+	}
+	return min, max, nil
+}
+
+// endsAfter returns true if any record on chrom ends after pos.
+func (tbx *Bix) endsAfter(chrom string, pos int) (bool, error) {
+	it, err := tbx.Query(interfaces.AsIPosition(chrom, pos, maxEnd))
+	if err != nil {
+		return false, err
+	}
+	defer it.Close()
+	for {
+		v, err := it.Next()
+		if err == io.EOF {
+			return false, nil
+		} else if err != nil {
+			return false, err
+		}
+		if int(v.End()) > pos {
+			return true, nil
+		}
+	}
+}
+
+// ContigExtentApprox is like ContigExtent but reads only the index, so it is
+// much faster for large files. min is at most the start of the first record
+// on chrom and max at least the greatest end, to the 16kb width of a tile of
+// the linear index, or of a larger bin for records at the ends of the contig
+// that span tiles.
+func (tbx *Bix) ContigExtentApprox(chrom string) (min, max int, err error) {
+	name, ok := tbx.CanonicalContig(chrom)
+	if !ok {
+		return 0, 0, errors.Wrapf(ErrUnknownContig, "%s not found in %s", chrom, tbx.path)
+	}
+	var qerr error
+	// hasChunks returns true if the index gives chunks for the region.
+	hasChunks := func(start, end int) bool {
+		if qerr != nil {
+			return false
+		}
+		chunks, err := tbx.Chunks(name, start, end)
+		if err != nil && err != index.ErrInvalid {
+			qerr = errors.Wrapf(err, "bix: error reading Chunks from %s", tbx.path)
+		}
+		return len(chunks) > 0
+	}
+	tiles := maxEnd / tileWidth
+	// the least tile after which no records start.
+	last := sort.Search(tiles+1, func(t int) bool { return !hasChunks(t*tileWidth, maxEnd) })
+	if qerr != nil {
+		return 0, 0, qerr
+	}
+	if last == 0 {
+		return 0, 0, errors.Errorf("bix: no records on %s in %s", chrom, tbx.path)
+	}
+	// the first tile that overlaps a record.
+	first := sort.Search(last, func(t int) bool { return hasChunks(0, (t+1)*tileWidth) })
+	if qerr != nil {
+		return 0, 0, qerr
+	}
+	if max = last * tileWidth; max > maxEnd {
+		max = maxEnd
+	}
+	return first * tileWidth, max, nil
+}
+
+// ErrNoRecords is returned by Bounds for a file without records.
+var ErrNoRecords = errors.New("bix: no records")
+
+// Bounds returns the first and last records of the file. The first is read
+// after the header. The last is on the last contig of the index with any
+// records, and is found with a binary search of queries that each read only
+// the chunks that the index gives for the end of the contig, as for
+// ContigExtent, so the file is not scanned. It returns an error with
+// ErrNoRecords as its cause if the file has no records.
+func (tbx *Bix) Bounds() (first, last interfaces.Relatable, err error) {
+	first, err = tbx.findRecord(nil, 0)
+	if err != nil {
+		return nil, nil, err
+	}
+	if first == nil {
+		return nil, nil, errors.Wrapf(ErrNoRecords, "%s", tbx.path)
+	}
+	names := tbx.Names()
+	for i := len(names) - 1; i >= 0; i-- {
+		name := names[i]
+		// find the greatest start of any record on the contig.
+		var qerr error
+		x := sort.Search(maxEnd, func(pos int) bool {
+			if qerr != nil {
+				return true
+			}
+			var v interfaces.Relatable
+			v, qerr = tbx.findRecord(interfaces.AsIPosition(name, pos, maxEnd), pos)
+			return v == nil
+		}) - 1
+		if qerr != nil {
+			return nil, nil, qerr
+		}
+		if x < 0 {
+			continue
+		}
+		if last, err = tbx.findRecord(interfaces.AsIPosition(name, x, maxEnd), -1); err != nil {
+			return nil, nil, err
+		}
+		return first, last, nil
+	}
+	return nil, nil, errors.Wrapf(ErrNoRecords, "%s", tbx.path)
+}
+
+// findRecord returns the first record in region that starts at or after
+// pos, or nil if there is none. If pos is negative, it returns the last
+// record in region instead.
+func (tbx *Bix) findRecord(region interfaces.IPosition, pos int) (interfaces.Relatable, error) {
+	bx, err := tbx.queryBixerator(region)
+	if err != nil {
+		return nil, err
+	}
+	defer bx.Close()
+	// the record is returned after the query is closed.
+	bx.keep = true
+	var last interfaces.Relatable
+	for {
+		v, err := bx.Next()
+		if err == io.EOF {
+			return last, nil
+		} else if err != nil {
+			return nil, err
+		}
+		if pos < 0 {
+			last = v
+		} else if int(v.Start()) >= pos {
+			return v, nil
+		}
+	}
+}
+
+// CountByColumn returns the number of records in region for each value of
+// the 1-based column col, such as the feature type of GFF. The records are
+// not parsed. For VCF, col must be one of the first 8 columns. A nil region
+// counts the records in the entire file.
+func (tbx *Bix) CountByColumn(region interfaces.IPosition, col int) (map[string]int, error) {
+	if col < 1 {
+		return nil, errors.Errorf("bix: invalid column %d", col)
+	}
+	bx, err := tbx.queryBixerator(region)
+	if err != nil {
+		return nil, err
+	}
+	defer bx.Close()
+
+	counts := make(map[string]int)
+	for {
+		toks, _, err := bx.readToks()
+		if err == io.EOF {
+			return counts, nil
+		} else if err != nil {
+			return nil, err
+		}
+		if len(toks) < col || (tbx.IsVCF() && col > 8) {
+			return nil, errors.Errorf("bix: column %d not found in record from %s", col, tbx.path)
+		}
+		counts[string(toks[col-1])]++
+	}
+}
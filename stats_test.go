@@ -0,0 +1,190 @@
+package bix
+
+import (
+	"fmt"
+
+	"github.com/biogo/hts/tabix"
+	"github.com/brentp/irelate/interfaces"
+	"github.com/pkg/errors"
+	. "gopkg.in/check.v1"
+)
+
+func (s *BixSuite) TestEstimateRecords(c *C) {
+	tbx, err := New("tests/csitest.bed.gz")
+	c.Assert(err, IsNil)
+	defer tbx.Close()
+	n, exact, err := tbx.EstimateRecords()
+	c.Assert(err, IsNil)
+	c.Check(exact, Equals, true)
+	c.Check(n, Equals, int64(9))
+
+	var lines []string
+	for i := 0; i < 100; i++ {
+		lines = append(lines, fmt.Sprintf("chr1\t%d\t%d", 1000+i*10, 1005+i*10))
+	}
+	bed, err := New(writeTabix(c, "est.bed.gz", bedIndex(), lines))
+	c.Assert(err, IsNil)
+	defer bed.Close()
+	n, exact, err = bed.EstimateRecords()
+	c.Assert(err, IsNil)
+	c.Check(exact, Equals, true)
+	c.Check(n, Equals, int64(100))
+
+	n, exact, err = bed.sampleRecords(200)
+	c.Assert(err, IsNil)
+	c.Check(exact, Equals, true)
+	c.Check(n, Equals, int64(100))
+
+	n, exact, err = bed.sampleRecords(20)
+	c.Assert(err, IsNil)
+	c.Check(exact, Equals, false)
+	c.Check(n > 80 && n < 120, Equals, true, Commentf("estimate: %d", n))
+}
+
+func (s *BixSuite) TestBounds(c *C) {
+	tbx, err := New(writeTabix(c, "bounds.bed.gz", bedIndex(), []string{
+		"#chrom\tstart\tend\tname",
+		"chr1\t100\t200\ta",
+		"chr1\t300\t400\tb",
+		"chr2\t10\t50000\tc",
+		"chr2\t20\t30\td",
+		"chr2\t20\t40\te",
+	}))
+	c.Assert(err, IsNil)
+	defer tbx.Close()
+	// the records must not be reused.
+	tbx.SetReuseBuffers(true)
+
+	first, last, err := tbx.Bounds()
+	c.Assert(err, IsNil)
+	c.Check(string(recordFields(first)[3]), Equals, "a")
+	c.Check(first.Chrom(), Equals, "chr1")
+	c.Check(string(recordFields(last)[3]), Equals, "e")
+	c.Check(last.Chrom(), Equals, "chr2")
+
+	// compare with a scan of a file with larger contigs.
+	csi, err := New("tests/csitest.bed.gz")
+	c.Assert(err, IsNil)
+	defer csi.Close()
+	it, err := csi.Query(nil)
+	c.Assert(err, IsNil)
+	recs := collect(c, it)
+	first, last, err = csi.Bounds()
+	c.Assert(err, IsNil)
+	for _, t := range [][2]interfaces.Relatable{{first, recs[0]}, {last, recs[len(recs)-1]}} {
+		c.Check(t[0].Chrom(), Equals, t[1].Chrom())
+		c.Check(t[0].Start(), Equals, t[1].Start())
+		c.Check(t[0].End(), Equals, t[1].End())
+	}
+
+	empty, err := New(writeTabix(c, "empty.bed.gz", bedIndex(), []string{"#chrom\tstart\tend"}))
+	c.Assert(err, IsNil)
+	defer empty.Close()
+	_, _, err = empty.Bounds()
+	c.Check(errors.Cause(err), Equals, ErrNoRecords)
+}
+
+func (s *BixSuite) TestContigExtent(c *C) {
+	tbx, err := New(writeTabix(c, "extent.bed.gz", bedIndex(), []string{
+		"chr1\t100\t200",
+		"chr1\t150\t900",
+		"chr1\t300\t400",
+		"chr2\t50\t50",
+		"chr2\t70\t70",
+	}))
+	c.Assert(err, IsNil)
+	defer tbx.Close()
+
+	for _, t := range []struct {
+		chrom    string
+		min, max int
+	}{{"chr1", 100, 900}, {"2", 50, 70}} {
+		min, max, err := tbx.ContigExtent(t.chrom)
+		c.Assert(err, IsNil)
+		c.Check(min, Equals, t.min, Commentf(t.chrom))
+		c.Check(max, Equals, t.max, Commentf(t.chrom))
+	}
+	_, _, err = tbx.ContigExtent("chr3")
+	c.Check(errors.Cause(err), Equals, ErrUnknownContig)
+
+	// compare with a scan of a file with larger contigs.
+	csi, err := New("tests/csitest.bed.gz")
+	c.Assert(err, IsNil)
+	defer csi.Close()
+	it, err := csi.Query(nil)
+	c.Assert(err, IsNil)
+	want := make(map[string][2]int)
+	for _, r := range collect(c, it) {
+		e, ok := want[r.Chrom()]
+		if !ok {
+			e[0] = int(r.Start())
+		}
+		if int(r.End()) > e[1] {
+			e[1] = int(r.End())
+		}
+		want[r.Chrom()] = e
+	}
+	for chrom, e := range want {
+		min, max, err := csi.ContigExtent(chrom)
+		c.Assert(err, IsNil)
+		c.Check([2]int{min, max}, Equals, e, Commentf(chrom))
+	}
+}
+
+func (s *BixSuite) TestContigExtentApprox(c *C) {
+	tbx, err := New(writeTabix(c, "approx.bed.gz", bedIndex(), []string{
+		"chr1\t100\t200",
+		"chr1\t300\t900",
+	}))
+	c.Assert(err, IsNil)
+	defer tbx.Close()
+	min, max, err := tbx.ContigExtentApprox("1")
+	c.Assert(err, IsNil)
+	c.Check([2]int{min, max}, Equals, [2]int{0, 16384})
+	_, _, err = tbx.ContigExtentApprox("chr3")
+	c.Check(errors.Cause(err), Equals, ErrUnknownContig)
+
+	// the approximate extent holds the exact one, to within a tile at each
+	// end for records that do not span tiles.
+	for _, path := range []string{"tests/csitest.bed.gz", "main/NA12878.wham.del.vcf.gz"} {
+		tbx, err := New(path)
+		c.Assert(err, IsNil)
+		defer tbx.Close()
+		for _, chrom := range tbx.Names() {
+			min, max, err := tbx.ContigExtent(chrom)
+			c.Assert(err, IsNil)
+			amin, amax, err := tbx.ContigExtentApprox(chrom)
+			c.Assert(err, IsNil)
+			comment := Commentf("%s %s: %d-%d, approx %d-%d", path, chrom, min, max, amin, amax)
+			c.Check(amin <= min && amax >= max, Equals, true, comment)
+			c.Check(min-amin < tileWidth && amax-max < tileWidth, Equals, true, comment)
+		}
+	}
+}
+
+func (s *BixSuite) TestCountByColumn(c *C) {
+	idx := tabix.New()
+	idx.NameColumn, idx.BeginColumn, idx.EndColumn = 1, 4, 5
+	tbx, err := New(writeTabix(c, "count.gff.gz", idx, []string{
+		"chr1\tsrc\tgene\t100\t900\t.\t+\t.\tID=g1",
+		"chr1\tsrc\texon\t100\t200\t.\t+\t.\tParent=g1",
+		"chr1\tsrc\texon\t300\t400\t.\t+\t.\tParent=g1",
+		"chr1\tsrc\texon\t800\t900\t.\t+\t.\tParent=g1",
+		"chr2\tsrc\tgene\t10\t20\t.\t-\t.\tID=g2",
+	}))
+	c.Assert(err, IsNil)
+	defer tbx.Close()
+
+	counts, err := tbx.CountByColumn(interfaces.AsIPosition("chr1", 150, 500), 3)
+	c.Assert(err, IsNil)
+	c.Check(counts, DeepEquals, map[string]int{"gene": 1, "exon": 2})
+
+	counts, err = tbx.CountByColumn(nil, 7)
+	c.Assert(err, IsNil)
+	c.Check(counts, DeepEquals, map[string]int{"+": 4, "-": 1})
+
+	_, err = tbx.CountByColumn(nil, 10)
+	c.Check(err, NotNil)
+	_, err = tbx.CountByColumn(nil, 0)
+	c.Check(err, NotNil)
+}
@@ -0,0 +1,95 @@
+package bix
+
+import (
+	"io"
+	"strings"
+
+	"github.com/biogo/hts/tabix"
+	"github.com/brentp/irelate/interfaces"
+	"github.com/pkg/errors"
+)
+
+// streamPath is the path of a Bix from NewStream, used in errors.
+const streamPath = "<stream>"
+
+// NewStream returns a &Bix that reads the bgzf data in r, such as a pipe
+// from stdin, without an index. The records are found with the 1-based
+// columns nameCol, beginCol and endCol, which may be 0 if there is no end
+// column, as for SetColumns, unless the header is VCF. As a stream cannot
+// seek, it may only be read once, from start to end, with Query(nil);
+// queries of a region and a second Query(nil) return an error, and methods
+// that need the index or to seek fail. If r is an io.Closer, it is closed by
+// Close.
+func NewStream(r io.Reader, nameCol, beginCol, endCol int, zeroBased bool) (*Bix, error) {
+	if nameCol < 1 || beginCol < 1 || endCol < 0 {
+		return nil, errors.Errorf("bix: invalid columns %d, %d, %d for stream", nameCol, beginCol, endCol)
+	}
+	idx := tabix.New()
+	idx.NameColumn, idx.BeginColumn, idx.EndColumn = int32(nameCol), int32(beginCol), int32(endCol)
+	idx.ZeroBased = zeroBased
+	idx.MetaChar = '#'
+
+	f := &streamFile{r: r}
+	tbx := &Bix{path: streamPath, file: f, stream: f, workers: 1, delim: '\t', strandColumn: 6}
+	bgz, err := tbx.openBlocks(f)
+	if err != nil {
+		return nil, err
+	}
+	tbx.bgzf = bgz
+	if tbx.streamLine, err = tbx.readFileHeader(tIndex{idx}); err != nil {
+		tbx.closeFile()
+		return nil, err
+	}
+	// the only query uses the file, so there is no copy to make for it.
+	tbx.serial = true
+	return tbx, nil
+}
+
+// queryStream is Query for a Bix from NewStream.
+func (tbx *Bix) queryStream(region interfaces.IPosition) (interfaces.RelatableIterator, error) {
+	if region != nil {
+		return nil, errors.Errorf("bix: %s is a stream and cannot be queried by region", tbx.path)
+	}
+	tbx2, err := tbx.acquire()
+	if err != nil {
+		return nil, err
+	}
+	if tbx2.stream.read {
+		tbx2.release()
+		return nil, errors.Errorf("bix: %s is a stream and has already been read", tbx.path)
+	}
+	tbx2.stream.read = true
+	buf := tbx2.buf
+	if tbx2.streamLine != "" {
+		// put back the first data line.
+		buf = tbx2.newReader(io.MultiReader(strings.NewReader(tbx2.streamLine), buf))
+	}
+	return &bixerator{buf: buf, tbx: tbx2}, nil
+}
+
+// streamFile is the data file of a Bix from NewStream, which can only be
+// read in order.
+type streamFile struct {
+	r io.Reader
+	// the records have been read by a query.
+	read bool
+}
+
+func (f *streamFile) Read(p []byte) (int, error) {
+	return f.r.Read(p)
+}
+
+func (f *streamFile) Seek(offset int64, whence int) (int64, error) {
+	return 0, errors.Errorf("bix: cannot seek in %s", streamPath)
+}
+
+func (f *streamFile) ReadAt(p []byte, off int64) (int, error) {
+	return 0, errors.Errorf("bix: cannot seek in %s", streamPath)
+}
+
+func (f *streamFile) Close() error {
+	if c, ok := f.r.(io.Closer); ok {
+		return c.Close()
+	}
+	return nil
+}
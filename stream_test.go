@@ -0,0 +1,75 @@
+package bix
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"os"
+
+	"github.com/biogo/hts/bgzf"
+	"github.com/brentp/irelate/interfaces"
+	. "gopkg.in/check.v1"
+)
+
+// onlyReader hides all but the Read method of a reader, as for a pipe.
+type onlyReader struct{ r io.Reader }
+
+func (o onlyReader) Read(p []byte) (int, error) { return o.r.Read(p) }
+
+func (s *BixSuite) TestNewStream(c *C) {
+	var b bytes.Buffer
+	w := bgzf.NewWriter(&b, 1)
+	_, err := w.Write([]byte("#chrom\tstart\tend\tname\nchr1\t10\t20\ta\nchr1\t30\t40\tb\nchr2\t5\t8\tc\n"))
+	c.Assert(err, IsNil)
+	c.Assert(w.Close(), IsNil)
+
+	tbx, err := NewStream(onlyReader{&b}, 1, 2, 3, true)
+	c.Assert(err, IsNil)
+	defer tbx.Close()
+	c.Check(tbx.ColumnNames(), DeepEquals, []string{"chrom", "start", "end", "name"})
+
+	_, err = tbx.Query(interfaces.AsIPosition("chr1", 0, 100))
+	c.Check(err, ErrorMatches, "bix: <stream> is a stream and cannot be queried by region")
+	_, err = tbx.Clone()
+	c.Check(err, ErrorMatches, "bix: <stream> is a stream and cannot be reopened")
+	// the stream is not closed by a failed SetWorkers.
+	c.Check(tbx.SetWorkers(2), ErrorMatches, "bix: <stream> is a stream and cannot be reopened with 2 workers")
+
+	it, err := tbx.Query(nil)
+	c.Assert(err, IsNil)
+	var got []string
+	for _, r := range collect(c, it) {
+		got = append(got, fmt.Sprintf("%s:%d-%d", r.Chrom(), r.Start(), r.End()))
+	}
+	c.Check(got, DeepEquals, []string{"chr1:10-20", "chr1:30-40", "chr2:5-8"})
+
+	_, err = tbx.Query(nil)
+	c.Check(err, ErrorMatches, "bix: <stream> is a stream and has already been read")
+
+	_, err = NewStream(onlyReader{&b}, 0, 2, 3, true)
+	c.Check(err, ErrorMatches, "bix: invalid columns .*")
+
+	// a VCF header is found from the stream.
+	f, err := os.Open("main/NA12878.wham.del.vcf.gz")
+	c.Assert(err, IsNil)
+	vcf, err := NewStream(onlyReader{f}, 1, 2, 0, false)
+	c.Assert(err, IsNil)
+	c.Check(vcf.IsVCF(), Equals, true)
+	it, err = vcf.Query(nil)
+	c.Assert(err, IsNil)
+	recs := collect(c, it)
+	c.Check(vcf.Close(), IsNil)
+	f.Close()
+
+	local, err := New("main/NA12878.wham.del.vcf.gz")
+	c.Assert(err, IsNil)
+	defer local.Close()
+	it, err = local.Query(nil)
+	c.Assert(err, IsNil)
+	want := collect(c, it)
+	c.Assert(recs, HasLen, len(want))
+	for i := range want {
+		c.Check(recs[i].Start(), Equals, want[i].Start())
+		c.Check(recs[i].End(), Equals, want[i].End())
+	}
+}
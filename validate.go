@@ -0,0 +1,127 @@
+package bix
+
+import (
+	"bytes"
+	"io"
+	"strconv"
+
+	"github.com/biogo/hts/bgzf"
+	"github.com/pkg/errors"
+)
+
+// ValidationReport is the result of Validate.
+type ValidationReport struct {
+	// Records is the number of records in the file.
+	Records int
+	// Unsorted is the first record that is not sorted by contig and start,
+	// or nil if the file is sorted.
+	Unsorted *InvalidRecord
+	// BadIntervals are the records whose end is before their start.
+	BadIntervals []InvalidRecord
+	// OutOfBounds are the records that end past the length given for their
+	// contig in the VCF header.
+	OutOfBounds []InvalidRecord
+}
+
+// OK returns true if no problems were found.
+func (r *ValidationReport) OK() bool {
+	return r.Unsorted == nil && len(r.BadIntervals) == 0 && len(r.OutOfBounds) == 0
+}
+
+// InvalidRecord is a record found by Validate.
+type InvalidRecord struct {
+	// Line is the 1-based line number of the record in the file.
+	Line int
+	// Offset is the offset of the start of the line in the uncompressed
+	// file.
+	Offset int64
+	// Text is the line without its line terminator.
+	Text string
+}
+
+// Validate reads the entire file and checks that the records are sorted as
+// tabix requires, each contig in a single run in order of start, and that
+// they have valid intervals within the contig lengths of a VCF header, if
+// any. Unsorted files give wrong results from queries. An error is returned
+// only if the file cannot be read or a record cannot be parsed.
+func (tbx *Bix) Validate() (*ValidationReport, error) {
+	tbx2, err := tbx.acquire()
+	if err != nil {
+		return nil, err
+	}
+	defer tbx2.release()
+	if err := tbx2.bgzf.Seek(bgzf.Offset{}); err != nil {
+		return nil, errors.Wrapf(err, "bix: error seeking in %s", tbx2.path)
+	}
+	buf := tbx2.newReader(tbx2.bgzf)
+
+	lengths := make(map[string]int)
+	if tbx2.VReader != nil {
+		for _, c := range tbx2.VReader.Header.Contigs {
+			if n, err := strconv.Atoi(c["length"]); err == nil {
+				lengths[c["ID"]] = n
+			}
+		}
+	}
+	ncols := tbx2.NameColumn()
+	for _, c := range []int{tbx2.BeginColumn(), tbx2.EndColumn(), tbx2.lengthColumn} {
+		if c > ncols {
+			ncols = c
+		}
+	}
+
+	report := &ValidationReport{}
+	seen := make(map[string]bool)
+	var chrom string
+	var prev, line int
+	var off int64
+	for {
+		l, err := readLine(buf, nil, tbx2.maxLine)
+		if err == ErrLineTooLong {
+			return nil, errors.Wrapf(err, "bix: line %d at offset %d of %s is longer than %d bytes", line+1, off, tbx2.path, tbx2.maxLine)
+		} else if err != nil && err != io.EOF {
+			return nil, errors.Wrapf(err, "bix: error reading %s", tbx2.path)
+		}
+		if len(l) == 0 {
+			break
+		}
+		line++
+		start := off
+		off += int64(len(l))
+		text := bytes.TrimRight(l, "\r\n")
+		if line <= tbx2.Index.Skip() || len(text) == 0 || rune(text[0]) == tbx2.Index.MetaChar() ||
+			(tbx2.lineFilter != nil && tbx2.lineFilter(text)) {
+			continue
+		}
+
+		report.Records++
+		toks := tbx2.split(text)
+		if len(toks) < ncols {
+			return nil, errors.Errorf("bix: line %d of %s has %d fields; expected at least %d", line, tbx2.path, len(toks), ncols)
+		}
+		s, e, err := genericBounds(toks, tbx2.BeginColumn()-1, tbx2.EndColumn()-1, tbx2.lengthColumn-1, tbx2.ZeroBased())
+		if err != nil {
+			return nil, errors.Wrapf(err, "bix: error parsing line %d of %s", line, tbx2.path)
+		}
+		c := string(toks[tbx2.NameColumn()-1])
+		rec := InvalidRecord{Line: line, Offset: start, Text: string(text)}
+
+		if c != chrom {
+			if seen[c] && report.Unsorted == nil {
+				report.Unsorted = &rec
+			}
+			seen[c] = true
+			chrom = c
+		} else if s < prev && report.Unsorted == nil {
+			report.Unsorted = &rec
+		}
+		prev = s
+		if e < s {
+			report.BadIntervals = append(report.BadIntervals, rec)
+		}
+		if n, ok := lengths[c]; ok && e > n {
+			report.OutOfBounds = append(report.OutOfBounds, rec)
+		}
+	}
+	return report, nil
+}
@@ -0,0 +1,78 @@
+package bix
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/biogo/hts/bgzf"
+	. "gopkg.in/check.v1"
+)
+
+// withData returns a Bix for the lines, compressed without an index, using
+// the index of the file at indexed.
+func withData(c *C, indexed string, lines []string) *Bix {
+	path := filepath.Join(c.MkDir(), "data.gz")
+	f, err := os.Create(path)
+	c.Assert(err, IsNil)
+	w := bgzf.NewWriter(f, 1)
+	_, err = w.Write([]byte(strings.Join(lines, "\n") + "\n"))
+	c.Assert(err, IsNil)
+	c.Assert(w.Close(), IsNil)
+	c.Assert(f.Close(), IsNil)
+	tbx, err := NewWithIndex(path, indexed+".tbi", 1)
+	c.Assert(err, IsNil)
+	return tbx
+}
+
+func (s *BixSuite) TestValidate(c *C) {
+	lines := []string{
+		"#chrom\tstart\tend",
+		"chr1\t10\t20",
+		"chr1\t30\t40",
+		"chr2\t5\t8",
+	}
+	path := writeTabix(c, "valid.bed.gz", bedIndex(), lines)
+	tbx, err := New(path)
+	c.Assert(err, IsNil)
+	defer tbx.Close()
+	r, err := tbx.Validate()
+	c.Assert(err, IsNil)
+	c.Check(r.OK(), Equals, true)
+	c.Check(r.Records, Equals, 3)
+
+	bad := withData(c, path, []string{
+		"#chrom\tstart\tend",
+		"chr1\t10\t20",
+		"chr1\t30\t25",
+		"chr2\t5\t8",
+		"chr1\t50\t60",
+		"chr1\t40\t45",
+	})
+	defer bad.Close()
+	r, err = bad.Validate()
+	c.Assert(err, IsNil)
+	c.Check(r.OK(), Equals, false)
+	c.Check(r.Records, Equals, 5)
+	c.Assert(r.Unsorted, NotNil)
+	c.Check(*r.Unsorted, Equals, InvalidRecord{Line: 5, Offset: 48, Text: "chr1\t50\t60"})
+	c.Check(r.BadIntervals, DeepEquals, []InvalidRecord{{Line: 3, Offset: 28, Text: "chr1\t30\t25"}})
+
+	bad = withData(c, path, []string{"chr1\tx\t20"})
+	defer bad.Close()
+	_, err = bad.Validate()
+	c.Check(err, ErrorMatches, "bix: error parsing line 1 of .*")
+}
+
+func (s *BixSuite) TestValidateContigLength(c *C) {
+	tbx, err := New(chromVCF(c, "chr1", "S1", []string{"##contig=<ID=chr1,length=150>"}, 100, 200))
+	c.Assert(err, IsNil)
+	defer tbx.Close()
+	r, err := tbx.Validate()
+	c.Assert(err, IsNil)
+	c.Check(r.Records, Equals, 2)
+	c.Check(r.Unsorted, IsNil)
+	c.Assert(r.OutOfBounds, HasLen, 1)
+	c.Check(r.OutOfBounds[0].Line, Equals, 6)
+	c.Check(strings.HasPrefix(r.OutOfBounds[0].Text, "chr1\t200\t"), Equals, true)
+}
@@ -0,0 +1,117 @@
+package bix
+
+import (
+	"github.com/brentp/irelate/interfaces"
+	"github.com/brentp/vcfgo"
+	"github.com/pkg/errors"
+)
+
+// VariantIterator returns the records from a query on a VCF as
+// *vcfgo.Variant.
+type VariantIterator struct {
+	it interfaces.RelatableIterator
+	// the last variant returned by Next and its end as given by Query.
+	cur    *vcfgo.Variant
+	curEnd uint32
+	// the genotypes of last, cached by Genotypes.
+	last *vcfgo.Variant
+	gts  []Genotype
+}
+
+// Genotype is the GT field of a sample.
+type Genotype struct {
+	// Alleles are the allele indexes, 0 for the reference and -1 for a
+	// missing allele.
+	Alleles []int
+	Phased  bool
+}
+
+// QueryVariants is like Query but the iterator returns *vcfgo.Variant values,
+// unwrapping any EndVariant. Use the iterator's End for the end that Query
+// gives each record. It returns an error if the file is not VCF.
+func (tbx *Bix) QueryVariants(region interfaces.IPosition) (*VariantIterator, error) {
+	if tbx.VReader == nil {
+		return nil, errors.Errorf("bix: %s is not VCF", tbx.path)
+	}
+	it, err := tbx.Query(region)
+	if err != nil {
+		return nil, err
+	}
+	return &VariantIterator{it: it}, nil
+}
+
+// Next returns the next variant or io.EOF when there are no more.
+func (v *VariantIterator) Next() (*vcfgo.Variant, error) {
+	r, err := v.it.Next()
+	if err != nil {
+		return nil, err
+	}
+	if vv := asVariant(r); vv != nil {
+		v.cur, v.curEnd = vv, r.End()
+		return vv, nil
+	}
+	return nil, errors.Errorf("bix: unexpected record type %T", r)
+}
+
+// End returns the end of variant as Query gives it if it is the last variant
+// returned by Next, which differs from variant.End() for an EndVariant, and
+// variant.End() otherwise.
+func (v *VariantIterator) End(variant *vcfgo.Variant) uint32 {
+	if variant == v.cur {
+		return v.curEnd
+	}
+	return variant.End()
+}
+
+// Genotypes returns the genotype of each sample of variant, in the order of
+// the header's samples, or nil if it has no samples. A sample without a GT
+// has no Alleles. The FORMAT columns are not parsed until the first call for
+// a variant and the result for the most recent variant is cached, so calling
+// this repeatedly for the same variant, as in a loop over its samples, does no
+// further work. The returned slice must not be modified.
+func (v *VariantIterator) Genotypes(variant *vcfgo.Variant) []Genotype {
+	if variant == v.last {
+		return v.gts
+	}
+	v.last, v.gts = variant, nil
+	if variant.Header == nil {
+		return nil
+	}
+	// vcfgo reports an error for valid but sparse samples such as "./."
+	// and still parses them, so errors are ignored.
+	variant.Header.ParseSamples(variant)
+	if len(variant.Samples) == 0 {
+		return nil
+	}
+	v.gts = make([]Genotype, len(variant.Samples))
+	for i, s := range variant.Samples {
+		if s != nil {
+			v.gts[i] = Genotype{Alleles: s.GT, Phased: s.Phased}
+		}
+	}
+	return v.gts
+}
+
+// Close closes the underlying iterator.
+func (v *VariantIterator) Close() error {
+	return v.it.Close()
+}
+
+// asVariant returns the *vcfgo.Variant wrapped by a record from a VCF or nil
+// if r is not one.
+func asVariant(r interfaces.Relatable) *vcfgo.Variant {
+	var iv interfaces.IVariant
+	switch w := r.(type) {
+	case interfaces.VarWrap:
+		iv = w.IVariant
+	case *rawVariant:
+		iv = w.IVariant
+	}
+	switch v := iv.(type) {
+	case *vcfgo.Variant:
+		return v
+	case *EndVariant:
+		return v.Variant
+	}
+	return nil
+}
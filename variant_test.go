@@ -0,0 +1,73 @@
+package bix
+
+import (
+	"io"
+
+	"github.com/biogo/hts/tabix"
+	"github.com/brentp/irelate/interfaces"
+	. "gopkg.in/check.v1"
+)
+
+func (s *BixSuite) TestQueryVariants(c *C) {
+	tbx, err := New("main/NA12878.wham.del.vcf.gz")
+	c.Assert(err, IsNil)
+	defer tbx.Close()
+
+	for _, raw := range []bool{false, true} {
+		tbx.SetRawLines(raw)
+		it, err := tbx.QueryVariants(interfaces.AsIPosition("1", 755600, 755700))
+		c.Assert(err, IsNil)
+		v, err := it.Next()
+		c.Assert(err, IsNil)
+		c.Check(v.Pos, Equals, uint64(755638))
+		c.Check(v.Chromosome, Equals, "1")
+		for err == nil {
+			_, err = it.Next()
+		}
+		c.Check(err, Equals, io.EOF)
+		c.Check(it.Close(), IsNil)
+	}
+
+	bed, err := New("tests/csitest.bed.gz")
+	c.Assert(err, IsNil)
+	defer bed.Close()
+	_, err = bed.QueryVariants(interfaces.AsIPosition("1", 0, 100))
+	c.Check(err, ErrorMatches, "bix: .* is not VCF")
+}
+
+func (s *BixSuite) TestGenotypes(c *C) {
+	idx := tabix.New()
+	idx.NameColumn, idx.BeginColumn, idx.EndColumn = 1, 2, 0
+	idx.MetaChar = '#'
+	tbx, err := New(writeTabix(c, "genotypes.vcf.gz", idx, []string{
+		"##fileformat=VCFv4.1",
+		`##FORMAT=<ID=GT,Number=1,Type=String,Description="Genotype">`,
+		`##FORMAT=<ID=DP,Number=1,Type=Integer,Description="Depth">`,
+		"#CHROM\tPOS\tID\tREF\tALT\tQUAL\tFILTER\tINFO\tFORMAT\tS1\tS2\tS3",
+		"1\t100\t.\tA\tG,T\t50\tPASS\t.\tGT:DP\t0/1:10\t1|2:.\t./.:.",
+		"1\t200\t.\tA\tG\t50\tPASS\t.\tDP\t5\t6\t7",
+	}))
+	c.Assert(err, IsNil)
+	defer tbx.Close()
+
+	it, err := tbx.QueryVariants(interfaces.AsIPosition("1", 0, 1000))
+	c.Assert(err, IsNil)
+	defer it.Close()
+	v, err := it.Next()
+	c.Assert(err, IsNil)
+	gts := it.Genotypes(v)
+	c.Check(gts, DeepEquals, []Genotype{
+		{Alleles: []int{0, 1}},
+		{Alleles: []int{1, 2}, Phased: true},
+		{Alleles: []int{-1, -1}},
+	})
+	// the same variant is not parsed again.
+	again := it.Genotypes(v)
+	c.Check(&again[0], Equals, &gts[0])
+
+	v, err = it.Next()
+	c.Assert(err, IsNil)
+	gts = it.Genotypes(v)
+	c.Assert(gts, HasLen, 3)
+	c.Check(gts[0].Alleles, HasLen, 0)
+}